@@ -1,15 +1,58 @@
 package flo
 
 import (
+	"github.com/toxyl/flo/checksum"
 	c "github.com/toxyl/flo/codec"
+	"github.com/toxyl/flo/config"
 )
 
 func (f *FileObj) checksum(codec *c.Codec) string {
 	s := ""
+	if config.ChecksumCache != nil && codec.Hash != nil {
+		if sum, err := config.ChecksumCache.Sum(codec, f.Path()); err == nil {
+			s = codec.Format(sum)
+		}
+		return s
+	}
 	f.read(codec, &s)
 	return s
 }
 
+// Digest returns the raw digest of the file calculated with the checksum codec `algo`.
+// If config.ChecksumCache is set, it is used.
+func (f *FileObj) Digest(algo *c.Codec) ([]byte, error) {
+	if config.ChecksumCache != nil && algo.Hash != nil {
+		return config.ChecksumCache.Sum(algo, f.Path())
+	}
+	var sum []byte
+	if err := f.read(algo, &sum); err != nil {
+		return nil, err
+	}
+	return sum, nil
+}
+
+// ChecksumWith returns the checksum of the file calculated with the algorithm registered as `name`.
+func (f *FileObj) ChecksumWith(name string) (string, error) {
+	algo, err := checksum.Get(name)
+	if err != nil {
+		return "", err
+	}
+	s := ""
+	if err := f.read(algo, &s); err != nil {
+		return "", err
+	}
+	return s, nil
+}
+
+// Sums calculates the checksums of all `algos` in a single pass over the file and returns them by codec name.
+func (f *FileObj) Sums(algos ...*c.Codec) (map[string]string, error) {
+	h, err := checksum.HashFile(f.Path(), algos...)
+	if err != nil {
+		return nil, err
+	}
+	return h.Sums(), nil
+}
+
 func (f *FileObj) SHA1() string   { return f.checksum(c.SHA1) }
 func (f *FileObj) SHA256() string { return f.checksum(c.SHA256) }
 func (f *FileObj) SHA512() string { return f.checksum(c.SHA512) }
@@ -0,0 +1,161 @@
+package checksum
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/toxyl/flo/codec"
+	"github.com/toxyl/flo/errors"
+	"github.com/toxyl/flo/utils"
+)
+
+// FileKey identifies a version of a file. As long as it doesn't change, the content
+// is assumed to be unchanged. Device, inode and change time are only available on linux.
+type FileKey struct {
+	Dev        uint64
+	Ino        uint64
+	Size       int64
+	ModTime    int64 // nanoseconds since the epoch
+	ChangeTime int64 // nanoseconds since the epoch
+}
+
+// CacheEntry holds the digests of a file by algorithm name.
+type CacheEntry struct {
+	Key  FileKey
+	Sums map[string][]byte
+}
+
+// Cache stores digests by path and reuses them as long as the FileKey of the file is unchanged.
+// It can be persisted with Save and restored with LoadCache to detect changes across restarts.
+type Cache struct {
+	mu      sync.RWMutex
+	entries map[string]*CacheEntry
+}
+
+func NewCache() *Cache {
+	return &Cache{
+		entries: map[string]*CacheEntry{},
+	}
+}
+
+// LoadCache reads a cache written by Save using the same `format` (e.g. codec.GOB or codec.JSON).
+func LoadCache(file string, format *codec.Codec) (*Cache, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, errors.ErrFailedToOpenFile(file, err)
+	}
+	c := NewCache()
+	if err := format.Decode(f, &c.entries); err != nil {
+		return nil, errors.ErrFailedToReadFile(file, err)
+	}
+	if c.entries == nil {
+		c.entries = map[string]*CacheEntry{}
+	}
+	return c, nil
+}
+
+// Save writes the cache atomically to `file` using `format` (e.g. codec.GOB or codec.JSON).
+func (c *Cache) Save(file string, format *codec.Codec) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return utils.AtomicWrite(file, 0755, func(w io.Writer) error { return format.Encode(c.entries, w) })
+}
+
+func (c *Cache) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.entries)
+}
+
+// Forget removes the entry of `path`.
+func (c *Cache) Forget(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, cachePath(path))
+}
+
+// Clear removes all entries.
+func (c *Cache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.entries)
+}
+
+// Prune removes the entries of files that no longer exist and returns how many were removed.
+func (c *Cache) Prune() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for path := range c.entries {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			delete(c.entries, path)
+			n++
+		}
+	}
+	return n
+}
+
+// Previous returns the last digest of `path` calculated with `algo`, even if the file has changed since.
+func (c *Cache) Previous(algo *codec.Codec, path string) []byte {
+	if algo == nil {
+		return nil
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if e, ok := c.entries[cachePath(path)]; ok {
+		return e.Sums[algo.Name]
+	}
+	return nil
+}
+
+// Sum returns the digest of `path` calculated with `algo`. The file is only read
+// if there is no digest for it yet or if its FileKey has changed.
+func (c *Cache) Sum(algo *codec.Codec, path string) ([]byte, error) {
+	if algo == nil || algo.Hash == nil {
+		return nil, errors.ErrChecksumAlgorithmInvalid(Name(algo))
+	}
+	path = cachePath(path)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, errors.ErrFailedToReadFile(path, err)
+	}
+	key := fileKeyOf(info)
+
+	c.mu.RLock()
+	e, ok := c.entries[path]
+	if ok && e.Key == key {
+		if sum, ok := e.Sums[algo.Name]; ok {
+			c.mu.RUnlock()
+			return sum, nil
+		}
+	}
+	c.mu.RUnlock()
+
+	h, err := HashFile(path, algo)
+	if err != nil {
+		return nil, err
+	}
+	sum := h.Sum(algo)
+	if after, err := os.Stat(path); err != nil || fileKeyOf(after) != key {
+		return sum, nil // changed while reading, don't cache
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok = c.entries[path]
+	if !ok || e.Key != key {
+		e = &CacheEntry{Key: key, Sums: map[string][]byte{}}
+		c.entries[path] = e
+	}
+	e.Sums[algo.Name] = sum
+	return sum, nil
+}
+
+func cachePath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
@@ -0,0 +1,18 @@
+//go:build linux
+
+package checksum
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+func fileKeyOf(info fs.FileInfo) FileKey {
+	key := FileKey{Size: info.Size(), ModTime: info.ModTime().UnixNano()}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		key.Dev = uint64(stat.Dev)
+		key.Ino = stat.Ino
+		key.ChangeTime = stat.Ctim.Nano()
+	}
+	return key
+}
@@ -0,0 +1,9 @@
+//go:build windows
+
+package checksum
+
+import "io/fs"
+
+func fileKeyOf(info fs.FileInfo) FileKey {
+	return FileKey{Size: info.Size(), ModTime: info.ModTime().UnixNano()}
+}
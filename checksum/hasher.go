@@ -0,0 +1,90 @@
+package checksum
+
+import (
+	"hash"
+	"io"
+	"os"
+
+	"github.com/toxyl/flo/codec"
+	"github.com/toxyl/flo/errors"
+)
+
+// Hasher calculates the checksums of several algorithms in a single pass over the data.
+// Write the data to it (e.g. using io.Copy) and read the digests using Sum, String or Sums.
+type Hasher struct {
+	algos  []*codec.Codec
+	hashes []hash.Hash
+	w      io.Writer
+}
+
+// NewHasher returns a Hasher for the given checksum codecs, e.g. codec.SHA256 and codec.MD5.
+func NewHasher(algos ...*codec.Codec) (*Hasher, error) {
+	h := &Hasher{
+		algos:  algos,
+		hashes: make([]hash.Hash, len(algos)),
+	}
+	writers := make([]io.Writer, len(algos))
+	for i, algo := range algos {
+		if algo == nil || algo.Hash == nil {
+			return nil, errors.ErrChecksumAlgorithmInvalid(Name(algo))
+		}
+		h.hashes[i] = algo.Hash()
+		writers[i] = h.hashes[i]
+	}
+	h.w = io.MultiWriter(writers...)
+	return h, nil
+}
+
+// HashFile returns a Hasher that has read the entire `file`.
+func HashFile(file string, algos ...*codec.Codec) (*Hasher, error) {
+	h, err := NewHasher(algos...)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, errors.ErrFailedToOpenFile(file, err)
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, errors.ErrFailedToReadFile(file, err)
+	}
+	return h, nil
+}
+
+func (h *Hasher) Write(p []byte) (int, error) { return h.w.Write(p) }
+
+// Reset discards all data written so far.
+func (h *Hasher) Reset() {
+	for _, hh := range h.hashes {
+		hh.Reset()
+	}
+}
+
+// Sum returns the raw digest of `algo` or nil if the Hasher doesn't calculate it.
+func (h *Hasher) Sum(algo *codec.Codec) []byte {
+	for i, a := range h.algos {
+		if a == algo {
+			return h.hashes[i].Sum(nil)
+		}
+	}
+	return nil
+}
+
+// String returns the formatted digest of `algo` or an empty string if the Hasher doesn't calculate it.
+func (h *Hasher) String(algo *codec.Codec) string {
+	sum := h.Sum(algo)
+	if sum == nil {
+		return ""
+	}
+	return algo.Format(sum)
+}
+
+// Sums returns the formatted digests of all algorithms by name.
+func (h *Hasher) Sums() map[string]string {
+	res := make(map[string]string, len(h.algos))
+	for i, algo := range h.algos {
+		res[algo.Name] = algo.Format(h.hashes[i].Sum(nil))
+	}
+	return res
+}
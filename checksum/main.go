@@ -4,19 +4,49 @@ import (
 	"os"
 
 	"github.com/toxyl/flo/codec"
+	"github.com/toxyl/flo/errors"
 )
 
 type Checksum struct {
 	algo    *codec.Codec
 	file    string
 	val     string
+	raw     []byte
 	changed bool
+	updated bool
+	cache   *Cache
 }
 
-func (c *Checksum) sum() string {
-	s := ""
-	file, _ := os.Open(c.file)
-	_ = c.algo.Decode(file, &s)
+// digest streams the file through the hash and returns the raw digest.
+func (c *Checksum) digest() ([]byte, error) {
+	if c.algo == nil || c.algo.Hash == nil {
+		return nil, errors.ErrChecksumAlgorithmInvalid(Name(c.algo))
+	}
+	if c.cache != nil {
+		return c.cache.Sum(c.algo, c.file)
+	}
+	var sum []byte
+	file, err := os.Open(c.file)
+	if err != nil {
+		return nil, errors.ErrFailedToOpenFile(c.file, err)
+	}
+	if err := c.algo.Decode(file, &sum); err != nil {
+		return nil, errors.ErrFailedToReadFile(c.file, err)
+	}
+	return sum, nil
+}
+
+func (c *Checksum) sum() (string, error) {
+	sum, err := c.digest()
+	if err != nil {
+		return "", err
+	}
+	return c.algo.Format(sum), nil
+}
+
+// sumOrEmpty returns the checksum or an empty string if the file can't be read
+func (c *Checksum) sumOrEmpty() string {
+	s, _ := c.sum()
 	return s
 }
 
@@ -24,18 +54,45 @@ func (c *Checksum) Get() string {
 	return c.val
 }
 
-func (c *Checksum) Update() {
-	valid := false
-	switch c.algo {
-	case codec.SHA1, codec.SHA256, codec.SHA512, codec.MD5, codec.CRC32, codec.CRC64:
-		valid = true
+// Bytes returns the raw digest of the last Update.
+func (c *Checksum) Bytes() []byte {
+	return c.raw
+}
+
+// Update recalculates the checksum. If the file can't be read,
+// the checksum becomes empty and the error is returned.
+// An error is also returned if the algorithm is not a checksum codec.
+//
+// If a cache is used, the first Update compares against the digest stored in the cache,
+// so Changed also reports changes that happened while the process wasn't running.
+func (c *Checksum) Update() error {
+	prev := c.val
+	if !c.updated && c.cache != nil && c.algo != nil {
+		if p := c.cache.Previous(c.algo, c.file); p != nil && c.algo.Format != nil {
+			prev = c.algo.Format(p)
+		}
 	}
-	if !valid {
-		panic("you must choose a valid checksum algorithm (SHA1, SHA256, SHA512, MD5, CRC32 or CRC64)")
+	c.updated = true
+	s := ""
+	sum, err := c.digest()
+	if err == nil {
+		s = c.algo.Format(sum)
 	}
-	s := c.sum()
-	c.changed = c.val != s
+	c.changed = prev != s
 	c.val = s
+	c.raw = sum
+	return err
+}
+
+// Algorithm returns the codec used to calculate the checksum.
+func (c *Checksum) Algorithm() *codec.Codec {
+	return c.algo
+}
+
+// WithCache makes the checksum use `cache` to avoid rereading unchanged files, nil disables caching.
+func (c *Checksum) WithCache(cache *Cache) *Checksum {
+	c.cache = cache
+	return c
 }
 
 func (c *Checksum) Changed() bool {
@@ -43,15 +100,24 @@ func (c *Checksum) Changed() bool {
 }
 
 func (c *Checksum) Matches(other *Checksum) bool {
-	return c.sum() == other.sum()
+	return c.sumOrEmpty() == other.sumOrEmpty()
 }
 
 func (c *Checksum) MatchesString(str string) bool {
-	return c.sum() == str
+	return c.sumOrEmpty() == str
 }
 
 func (c *Checksum) MatchesBytes(data []byte) bool {
-	return c.sum() == c.algo.EncodeString(data)
+	return c.sumOrEmpty() == c.algo.EncodeString(data)
+}
+
+// NewByName works like New, but looks up the algorithm in the registry.
+func NewByName(algo, path string) (*Checksum, error) {
+	c, err := Get(algo)
+	if err != nil {
+		return nil, err
+	}
+	return New(c, path), nil
 }
 
 func New(algo *codec.Codec, path string) *Checksum {
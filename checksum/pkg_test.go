@@ -0,0 +1,144 @@
+package checksum
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/toxyl/flo/codec"
+)
+
+func TestHasher(t *testing.T) {
+	algos := []*codec.Codec{codec.SHA1, codec.SHA256, codec.SHA512, codec.MD5, codec.CRC32, codec.CRC64}
+	data := strings.Repeat("the quick brown fox jumps over the lazy dog\n", 1000)
+
+	h, err := NewHasher(algos...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.Copy(h, strings.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	for _, algo := range algos {
+		want := algo.EncodeString([]byte(data))
+		if got := h.String(algo); got != want {
+			t.Errorf("%s: got %s, want %s", algo.Name, got, want)
+		}
+		decoded := ""
+		if err := algo.DecodeString(data, &decoded); err != nil || decoded != want {
+			t.Errorf("%s: decoded %s (%v), want %s", algo.Name, decoded, err, want)
+		}
+		raw := []byte{}
+		if err := algo.DecodeString(data, &raw); err != nil || algo.Format(raw) != want {
+			t.Errorf("%s: raw digest %x (%v) doesn't match %s", algo.Name, raw, err, want)
+		}
+	}
+	if got := h.String(codec.JSON); got != "" {
+		t.Errorf("expected no digest for codec not used by the hasher, got %s", got)
+	}
+	if _, err := NewHasher(codec.JSON); err == nil {
+		t.Errorf("expected error for non-checksum codec")
+	}
+}
+
+func TestChecksum_Update(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "data")
+	if err := os.WriteFile(file, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := New(codec.CRC32, file)
+	if err := c.Update(); err != nil {
+		t.Fatal(err)
+	}
+	if c.Get() != "907060870" || !c.Changed() || len(c.Bytes()) != 4 {
+		t.Errorf("unexpected checksum %s (%x)", c.Get(), c.Bytes())
+	}
+	if !c.MatchesBytes([]byte("hello")) {
+		t.Errorf("expected checksum to match the data")
+	}
+	if err := c.Update(); err != nil || c.Changed() {
+		t.Errorf("expected unchanged checksum, got %v (%v)", c.Changed(), err)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	tests := map[string]string{
+		"sha224":      "ea09ae9cc6768c50fcee903ed054556e5bfc8347907f12598aa24193",
+		"SHA384":      "59e1748777448c69de6b800d7a33bbfb9ff1b463e44354c3553bcdb9c666fa90125a3c79f90397bdf5f6a13de828684f",
+		"sha3-256":    "3338be694f50c5f338814986cdf0686453a888b84f424d792af4b9202398f392",
+		"blake2b-256": "324dcf027dd4a30a932c441f365a25e86b173defa4b8e58948253471b81b72cf",
+		"blake3":      "ea8f163db38682925e4491c5e58d4bb3506ef8c14eb78a86e908c5624a67200f",
+		"xxh64":       "26c7827d889f6da3",
+	}
+	for name, want := range tests {
+		algo, err := Get(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := algo.EncodeString([]byte("hello")); got != want {
+			t.Errorf("%s: got %s, want %s", name, got, want)
+		}
+	}
+	if _, err := Get("nope"); err == nil {
+		t.Errorf("expected error for unknown algorithm")
+	}
+	if err := RegisterCodec(codec.JSON); err == nil {
+		t.Errorf("expected error when registering a codec that is not a checksum")
+	}
+	if err := New(codec.JSON, "").Update(); err == nil {
+		t.Errorf("expected error when updating with a codec that is not a checksum")
+	}
+}
+
+func TestCache(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "data")
+	if err := os.WriteFile(file, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cache := NewCache()
+	sum, err := cache.Sum(codec.SHA256, file)
+	if err != nil || codec.FormatHex(sum) != codec.SHA256.EncodeString([]byte("hello")) {
+		t.Fatalf("unexpected digest %x (%v)", sum, err)
+	}
+
+	// a cached digest is reused as long as the file key doesn't change
+	cache.entries[file].Sums[codec.SHA256.Name] = []byte("cached")
+	if sum, _ := cache.Sum(codec.SHA256, file); string(sum) != "cached" {
+		t.Errorf("expected cached digest, got %x", sum)
+	}
+
+	for _, format := range []*codec.Codec{codec.GOB, codec.JSON} {
+		cacheFile := filepath.Join(dir, "cache."+format.Name)
+		if err := cache.Save(cacheFile, format); err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := LoadCache(cacheFile, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sum, _ := loaded.Sum(codec.SHA256, file); string(sum) != "cached" {
+			t.Errorf("%s: expected cached digest after loading, got %x", format.Name, sum)
+		}
+	}
+
+	// a fresh checksum detects changes made "while the process wasn't running"
+	if err := os.WriteFile(file, []byte("hello world"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := New(codec.SHA256, file).WithCache(cache)
+	if err := c.Update(); err != nil || !c.Changed() || c.Get() != codec.SHA256.EncodeString([]byte("hello world")) {
+		t.Errorf("expected changed checksum, got %s (%v)", c.Get(), err)
+	}
+	c = New(codec.SHA256, file).WithCache(cache)
+	if err := c.Update(); err != nil || c.Changed() {
+		t.Errorf("expected unchanged checksum (%v)", err)
+	}
+
+	os.Remove(file)
+	if n := cache.Prune(); n != 1 || cache.Len() != 0 {
+		t.Errorf("expected 1 pruned entry, got %d (%d left)", n, cache.Len())
+	}
+}
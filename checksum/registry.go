@@ -0,0 +1,75 @@
+package checksum
+
+import (
+	"hash"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/toxyl/flo/codec"
+	"github.com/toxyl/flo/errors"
+)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]*codec.Codec{}
+)
+
+func init() {
+	for _, algo := range []*codec.Codec{
+		codec.MD5, codec.SHA1, codec.SHA224, codec.SHA256, codec.SHA384, codec.SHA512,
+		codec.SHA3_256, codec.SHA3_512, codec.BLAKE2B256, codec.BLAKE2B512, codec.BLAKE3,
+		codec.CRC32, codec.CRC64, codec.XXH64,
+	} {
+		_ = RegisterCodec(algo)
+	}
+}
+
+// Register creates a checksum codec (see codec.NewHashCodec) and registers it as `name`.
+// An already registered algorithm with the same name is replaced.
+func Register(name string, fnHash func() hash.Hash, fnFormat func(sum []byte) string) *codec.Codec {
+	c := codec.NewHashCodec(name, fnHash, fnFormat)
+	_ = RegisterCodec(c)
+	return c
+}
+
+// RegisterCodec registers a checksum codec under its name, it must have been created with codec.NewHashCodec.
+func RegisterCodec(c *codec.Codec) error {
+	if c == nil || c.Hash == nil || c.Format == nil {
+		return errors.ErrChecksumAlgorithmInvalid(Name(c))
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[strings.ToLower(c.Name)] = c
+	return nil
+}
+
+// Get returns the algorithm registered as `name` (case-insensitive).
+func Get(name string) (*codec.Codec, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	if c, ok := registry[strings.ToLower(name)]; ok {
+		return c, nil
+	}
+	return nil, errors.ErrChecksumAlgorithmInvalid(name)
+}
+
+// Algorithms returns the sorted names of all registered algorithms.
+func Algorithms() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	res := make([]string, 0, len(registry))
+	for n := range registry {
+		res = append(res, n)
+	}
+	slices.Sort(res)
+	return res
+}
+
+// Name returns the name of the algorithm, or "<nil>" if it is nil.
+func Name(c *codec.Codec) string {
+	if c == nil {
+		return "<nil>"
+	}
+	return c.Name
+}
@@ -6,19 +6,22 @@ import (
 	"crypto/md5"
 	"crypto/sha1"
 	"crypto/sha256"
+	"crypto/sha3"
 	"crypto/sha512"
 	"encoding/base64"
 	"encoding/gob"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"hash"
 	"hash/crc32"
 	"hash/crc64"
 	"io"
 	"net/url"
-	"strconv"
 
+	"github.com/cespare/xxhash/v2"
+	"golang.org/x/crypto/blake2b"
 	"gopkg.in/yaml.v3"
+	"lukechampine.com/blake3"
 )
 
 var (
@@ -188,127 +191,19 @@ var (
 			return nil
 		},
 	)
-	SHA1 = NewCodec("sha1",
-		func(input any, output io.Writer) error {
-			buf := input.([]byte)
-			br := bytes.NewReader(buf)
-			hashed := sha1.New()
-			if _, err := io.Copy(hashed, br); err != nil {
-				return err
-			}
-			_, err := output.Write([]byte(hex.EncodeToString(hashed.Sum(nil))))
-			return err
-		},
-		func(source io.Reader, target any) error {
-			hashed := sha1.New()
-			if _, err := io.Copy(hashed, source); err != nil {
-				return err
-			}
-			*(target.(*string)) = hex.EncodeToString(hashed.Sum(nil))
-			return nil
-		},
-	)
-	SHA256 = NewCodec("sha256",
-		func(input any, output io.Writer) error {
-			buf := input.([]byte)
-			br := bytes.NewReader(buf)
-			hashed := sha256.New()
-			if _, err := io.Copy(hashed, br); err != nil {
-				return err
-			}
-			_, err := output.Write([]byte(hex.EncodeToString(hashed.Sum(nil))))
-			return err
-		},
-		func(source io.Reader, target any) error {
-			hashed := sha256.New()
-			if _, err := io.Copy(hashed, source); err != nil {
-				return err
-			}
-			*(target.(*string)) = hex.EncodeToString(hashed.Sum(nil))
-			return nil
-		},
-	)
-	SHA512 = NewCodec("sha512",
-		func(input any, output io.Writer) error {
-			buf := input.([]byte)
-			br := bytes.NewReader(buf)
-			hashed := sha512.New()
-			if _, err := io.Copy(hashed, br); err != nil {
-				return err
-			}
-			_, err := output.Write([]byte(hex.EncodeToString(hashed.Sum(nil))))
-			return err
-		},
-		func(source io.Reader, target any) error {
-			hashed := sha512.New()
-			if _, err := io.Copy(hashed, source); err != nil {
-				return err
-			}
-			*(target.(*string)) = hex.EncodeToString(hashed.Sum(nil))
-			return nil
-		},
-	)
-	MD5 = NewCodec("md5",
-		func(input any, output io.Writer) error {
-			buf := input.([]byte)
-			br := bytes.NewReader(buf)
-			hashed := md5.New()
-			if _, err := io.Copy(hashed, br); err != nil {
-				return err
-			}
-			_, err := output.Write([]byte(hex.EncodeToString(hashed.Sum(nil))))
-			return err
-		},
-		func(source io.Reader, target any) error {
-			hashed := md5.New()
-			if _, err := io.Copy(hashed, source); err != nil {
-				return err
-			}
-			*(target.(*string)) = hex.EncodeToString(hashed.Sum(nil))
-			return nil
-		},
-	)
-	CRC32 = NewCodec("crc32",
-		func(input any, output io.Writer) error {
-			buf := input.([]byte)
-			br := bytes.NewReader(buf)
-			table := crc32.MakeTable(crc32.IEEE)
-			hashed := crc32.New(table)
-			if _, err := io.Copy(hashed, br); err != nil {
-				return err
-			}
-			_, err := output.Write(hashed.Sum(nil))
-			return err
-		},
-		func(source io.Reader, target any) error {
-			content, err := io.ReadAll(source)
-			if err != nil {
-				return err
-			}
-			checksum := crc32.ChecksumIEEE(content)
-			*(target.(*string)) = strconv.FormatUint(uint64(checksum), 10)
-			return nil
-		},
-	)
-	CRC64 = NewCodec("crc64",
-		func(input any, output io.Writer) error {
-			buf := input.([]byte)
-			table := crc64.MakeTable(crc64.ISO)
-			checksum := crc64.Checksum(buf, table)
-			str := strconv.FormatUint(checksum, 10)
-			_, err := output.Write([]byte(str))
-			return err
-		},
-		func(source io.Reader, target any) error {
-			buf, err := io.ReadAll(source)
-			if err != nil {
-				return err
-			}
-			table := crc64.MakeTable(crc64.ISO)
-			checksum := crc64.Checksum(buf, table)
-			str := strconv.FormatUint(checksum, 10)
-			*(target.(*string)) = str
-			return nil
-		},
-	)
+	SHA1   = NewHashCodec("sha1", sha1.New, FormatHex)
+	SHA256 = NewHashCodec("sha256", sha256.New, FormatHex)
+	SHA512 = NewHashCodec("sha512", sha512.New, FormatHex)
+	MD5    = NewHashCodec("md5", md5.New, FormatHex)
+	CRC32  = NewHashCodec("crc32", func() hash.Hash { return crc32.NewIEEE() }, FormatDecimal)
+	CRC64  = NewHashCodec("crc64", func() hash.Hash { return crc64.New(crc64.MakeTable(crc64.ISO)) }, FormatDecimal)
+
+	SHA224     = NewHashCodec("sha224", sha256.New224, FormatHex)
+	SHA384     = NewHashCodec("sha384", sha512.New384, FormatHex)
+	SHA3_256   = NewHashCodec("sha3-256", func() hash.Hash { return sha3.New256() }, FormatHex)
+	SHA3_512   = NewHashCodec("sha3-512", func() hash.Hash { return sha3.New512() }, FormatHex)
+	BLAKE2B256 = NewHashCodec("blake2b-256", func() hash.Hash { h, _ := blake2b.New256(nil); return h }, FormatHex)
+	BLAKE2B512 = NewHashCodec("blake2b-512", func() hash.Hash { h, _ := blake2b.New512(nil); return h }, FormatHex)
+	BLAKE3     = NewHashCodec("blake3", func() hash.Hash { return blake3.New(32, nil) }, FormatHex)
+	XXH64      = NewHashCodec("xxh64", func() hash.Hash { return xxhash.New() }, FormatHex)
 )
@@ -0,0 +1,64 @@
+package codec
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"strconv"
+
+	"github.com/toxyl/flo/errors"
+)
+
+// FormatHex formats a digest as lowercase hex string.
+func FormatHex(sum []byte) string { return hex.EncodeToString(sum) }
+
+// FormatDecimal formats a big-endian digest of up to 8 bytes (e.g. CRC32 or CRC64) as decimal string.
+func FormatDecimal(sum []byte) string {
+	buf := make([]byte, 8)
+	copy(buf[8-min(len(sum), 8):], sum)
+	return strconv.FormatUint(binary.BigEndian.Uint64(buf), 10)
+}
+
+// NewHashCodec creates a checksum codec, `fnHash` creates the hash and `fnFormat` turns a digest into a string.
+//
+// Decoding streams the input through the hash, so it never has to be in memory as a whole.
+// The target can be a *string (formatted digest) or a *[]byte (raw digest).
+// Encoding hashes a []byte or string (anything else is hashed as fmt.Sprint would print it)
+// and writes the formatted digest.
+func NewHashCodec(name string, fnHash func() hash.Hash, fnFormat func(sum []byte) string) *Codec {
+	c := NewCodec(name,
+		func(input any, output io.Writer) error {
+			h := fnHash()
+			switch in := input.(type) {
+			case []byte:
+				h.Write(in)
+			case string:
+				io.WriteString(h, in)
+			default:
+				io.WriteString(h, fmt.Sprint(in))
+			}
+			_, err := io.WriteString(output, fnFormat(h.Sum(nil)))
+			return err
+		},
+		func(source io.Reader, target any) error {
+			h := fnHash()
+			if _, err := io.Copy(h, source); err != nil {
+				return err
+			}
+			switch t := target.(type) {
+			case *string:
+				*t = fnFormat(h.Sum(nil))
+			case *[]byte:
+				*t = h.Sum(nil)
+			default:
+				return errors.ErrUnsupportedTarget(target)
+			}
+			return nil
+		},
+	)
+	c.Hash = fnHash
+	c.Format = fnFormat
+	return c
+}
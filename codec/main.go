@@ -1,6 +1,7 @@
 package codec
 
 import (
+	"hash"
 	"io"
 
 	"github.com/toxyl/flo/errors"
@@ -15,6 +16,8 @@ type Codec struct {
 	Encode       func(input any, output io.Writer) error
 	EncodeString func(input any) string
 	EncodeBytes  func(input any) []byte
+	Hash         func() hash.Hash        // only set for checksum codecs
+	Format       func(sum []byte) string // only set for checksum codecs
 }
 
 func NewCodec(
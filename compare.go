@@ -16,3 +16,14 @@ func (f *FileObj) SameSHA512As(file *FileObj) bool { return f.compare(c.SHA512,
 func (f *FileObj) SameMD5As(file *FileObj) bool    { return f.compare(c.MD5, file) }
 func (f *FileObj) SameCRC32As(file *FileObj) bool  { return f.compare(c.CRC32, file) }
 func (f *FileObj) SameCRC64As(file *FileObj) bool  { return f.compare(c.CRC64, file) }
+
+// SameTreeAs compares the Merkle trees (see TreeChecksum) of both directories using config.ChecksumAlgorithm.
+// Directories that can't be read are never the same.
+func (d *DirObj) SameTreeAs(dir *DirObj, opts *TreeOptions) bool {
+	a, err := d.TreeChecksum(config.ChecksumAlgorithm, opts)
+	if err != nil {
+		return false
+	}
+	b, err := dir.TreeChecksum(config.ChecksumAlgorithm, opts)
+	return err == nil && a.Equal(b)
+}
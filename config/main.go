@@ -1,13 +1,21 @@
 package config
 
 import (
+	"github.com/toxyl/flo/checksum"
 	"github.com/toxyl/flo/codec"
 	"github.com/toxyl/glog"
 )
 
 var (
+	// ChecksumAlgorithm is used by FileObj.Checksum and FileObj.SameAs, see SetChecksumAlgorithm.
 	ChecksumAlgorithm = codec.SHA256
 	ColorMode         = true
+	// AtomicWrites makes all Store*, Write* and RenderFromTemplate calls write to a temporary file first
+	// which is then renamed to the target, so readers never see a partially written file.
+	AtomicWrites = false
+	// ChecksumCache is used for all checksums calculated by FileObj, if set.
+	// Files are then only reread if their size, mtime, ctime or inode have changed.
+	ChecksumCache *checksum.Cache = nil
 )
 var (
 	ModeNone   = glog.WrapGray("-")
@@ -49,3 +57,14 @@ var (
 	IndicatorLink       = glog.WrapPurple("┅⮞")
 	IndicatorNoLink     = glog.WrapPurple("  ")
 )
+
+// SetChecksumAlgorithm selects the ChecksumAlgorithm by its name in the checksum registry,
+// e.g. "sha256", "blake3" or "xxh64".
+func SetChecksumAlgorithm(name string) error {
+	algo, err := checksum.Get(name)
+	if err != nil {
+		return err
+	}
+	ChecksumAlgorithm = algo
+	return nil
+}
@@ -0,0 +1,55 @@
+package flo
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/toxyl/flo/errors"
+	"github.com/toxyl/flo/glob"
+	"github.com/toxyl/flo/utils"
+)
+
+// CopyTree copies this directory and everything below it to `destinationPath`, keeping the permissions.
+// Links are copied as links with the same target, like `cp -R` does.
+// If `filter` is not nil, only matching entries are copied (paths are relative to this directory).
+func (d *DirObj) CopyTree(destinationPath string, filter *glob.Filter) error {
+	dirMode := d.FileMode().Perm()
+	if err := os.MkdirAll(destinationPath, dirMode); err != nil && !os.IsExist(err) {
+		return errors.ErrFailedToCreateDir(destinationPath, err)
+	}
+	opts := DefaultWalkOptions()
+	opts.Filter = filter
+	for f, err := range d.Walk(opts) {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(d.Path(), f.Path())
+		if err != nil {
+			return errors.ErrFailedToCopyFile(f.Path(), destinationPath, err)
+		}
+		target := filepath.Join(destinationPath, rel)
+		switch f.Type() {
+		case TypeSymlink, TypeDanglingLink:
+			link, err := f.Readlink()
+			if err != nil {
+				return err
+			}
+			if err := os.MkdirAll(filepath.Dir(target), dirMode); err != nil && !os.IsExist(err) {
+				return errors.ErrFailedToCreateDir(filepath.Dir(target), err)
+			}
+			if err := os.Symlink(link, target); err != nil {
+				return errors.ErrFailedToCreateFile(target, err)
+			}
+			continue
+		case TypeDir:
+			if err := os.MkdirAll(target, f.FileMode().Perm()); err != nil && !os.IsExist(err) {
+				return errors.ErrFailedToCreateDir(target, err)
+			}
+			continue
+		}
+		if err := utils.FileCopy(f.Path(), target, dirMode, f.FileMode()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
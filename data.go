@@ -3,14 +3,20 @@ package flo
 import (
 	"bytes"
 	"html/template"
+	"io"
 
 	c "github.com/toxyl/flo/codec"
+	"github.com/toxyl/flo/config"
+	"github.com/toxyl/flo/errors"
 	"github.com/toxyl/flo/log"
 	"github.com/toxyl/flo/utils"
 )
 
 func (f *FileObj) read(codec *c.Codec, target any) error {
-	file, _ := f.OpenReadOnly()
+	file, err := f.TryOpenReadOnly()
+	if err != nil {
+		return err
+	}
 	return codec.Decode(file, target)
 }
 
@@ -77,22 +83,52 @@ func (f *FileObj) ReadBase64URL(target any) *FileObj    { return f.mustRead(c.BA
 func (f *FileObj) ReadBase64Std(target any) *FileObj    { return f.mustRead(c.BASE64_STD, target) }
 func (f *FileObj) ReadURL(target any) *FileObj          { return f.mustRead(c.URL, target) }
 
+func (f *FileObj) writeAtomic(fn func(w io.Writer) error) error {
+	defer f.invalidate()
+	return utils.AtomicWrite(f.Path(), f.Parent().meta().Mode, fn)
+}
+
 func (f *FileObj) write(codec *c.Codec, data any) error {
-	defer f.updateInfo()
-	file, err := utils.Mkfile(f.Path(), f.Parent().info.Mode, f.info.Mode)
+	return f.writeWith(codec, data, config.AtomicWrites)
+}
+
+func (f *FileObj) writeWith(codec *c.Codec, data any, atomic bool) error {
+	if atomic {
+		return f.writeAtomic(func(w io.Writer) error { return codec.Encode(data, w) })
+	}
+	defer f.invalidate()
+	file, err := utils.Mkfile(f.Path(), f.Parent().meta().Mode, f.meta().Mode)
 	if err != nil {
 		return err
 	}
-	return codec.Encode(data, file)
+	if err := codec.Encode(data, file); err != nil {
+		return errors.ErrFailedToWriteFile(f.Path(), err)
+	}
+	return nil
 }
 
 func (f *FileObj) mustWrite(codec *c.Codec, data any) *FileObj {
-	if err := f.write(codec, data); err != nil {
+	return f.mustWriteWith(codec, data, config.AtomicWrites)
+}
+
+func (f *FileObj) mustWriteWith(codec *c.Codec, data any, atomic bool) *FileObj {
+	if err := f.writeWith(codec, data, atomic); err != nil {
 		log.Panic("failed to write %s: %v", f.path, err)
 	}
 	return f
 }
 
+// StoreWith encodes `data` with the given `codec` and writes it to the file.
+// If `atomic` is true, the file is replaced atomically, regardless of `config.AtomicWrites`.
+func (f *FileObj) StoreWith(codec *c.Codec, data any, atomic bool) error {
+	return f.writeWith(codec, data, atomic)
+}
+
+// WriteWith works like StoreWith but panics if writing fails.
+func (f *FileObj) WriteWith(codec *c.Codec, data any, atomic bool) *FileObj {
+	return f.mustWriteWith(codec, data, atomic)
+}
+
 func (f *FileObj) StoreBytes(data []byte) error    { return f.write(c.BYTES, data) }
 func (f *FileObj) StoreBytesGZ(data []byte) error  { return f.write(c.BYTESGZ, data) }
 func (f *FileObj) StoreString(data string) error   { return f.write(c.STRING, data) }
@@ -106,6 +142,12 @@ func (f *FileObj) StoreBase64Std(data any) error   { return f.write(c.BASE64_STD
 func (f *FileObj) StoreURL(data any) error         { return f.write(c.URL, data) }
 
 func (f *FileObj) RenderFromTemplate(tmpl string, data any, fns template.FuncMap) error {
+	return f.RenderFromTemplateWith(tmpl, data, fns, config.AtomicWrites)
+}
+
+// RenderFromTemplateWith works like RenderFromTemplate.
+// If `atomic` is true, the file is replaced atomically, regardless of `config.AtomicWrites`.
+func (f *FileObj) RenderFromTemplateWith(tmpl string, data any, fns template.FuncMap, atomic bool) error {
 	t := template.New("new")
 	if fns != nil {
 		t.Funcs(fns)
@@ -114,9 +156,19 @@ func (f *FileObj) RenderFromTemplate(tmpl string, data any, fns template.FuncMap
 	if err != nil {
 		return err
 	}
-	outputFile, closer := f.OpenTruncate()
-	defer closer()
-	return t.Execute(outputFile, data)
+	if atomic {
+		return f.writeAtomic(func(w io.Writer) error { return t.Execute(w, data) })
+	}
+	defer f.invalidate()
+	outputFile, err := f.TryOpenTruncate()
+	if err != nil {
+		return err
+	}
+	defer outputFile.Close()
+	if err := t.Execute(outputFile, data); err != nil {
+		return errors.ErrFailedToWriteFile(f.Path(), err)
+	}
+	return nil
 }
 
 func (f *FileObj) WriteBytes(data []byte) *FileObj    { return f.mustWrite(c.BYTES, data) }
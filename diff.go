@@ -0,0 +1,96 @@
+package flo
+
+import (
+	"path/filepath"
+	"strings"
+
+	c "github.com/toxyl/flo/codec"
+	"github.com/toxyl/flo/diff"
+	"github.com/toxyl/flo/errors"
+)
+
+// content returns the file as string, a file that doesn't exist is empty.
+func (f *FileObj) content() (string, error) {
+	s := ""
+	if !f.Exists() {
+		return s, nil
+	}
+	if err := f.read(c.STRING, &s); err != nil {
+		return "", err
+	}
+	return s, nil
+}
+
+// Diff returns the unified diff turning the file into `other`, or an empty string if both are equal.
+// If `opts` is nil, diff.DefaultOptions() are used with the paths of both files as labels.
+func (f *FileObj) Diff(other *FileObj, opts *diff.Options) (string, error) {
+	if opts == nil {
+		opts = diff.DefaultOptions()
+		opts.LabelA, opts.LabelB = f.Path(), other.Path()
+	}
+	a, err := f.content()
+	if err != nil {
+		return "", err
+	}
+	b, err := other.content()
+	if err != nil {
+		return "", err
+	}
+	return diff.Unified(a, b, opts), nil
+}
+
+// ApplyPatch applies a unified diff to the file using diff.DefaultApplyOptions(),
+// see ApplyPatchWith for details.
+func (f *FileObj) ApplyPatch(patch string) (*diff.ApplyResult, error) {
+	return f.ApplyPatchWith(patch, nil, true)
+}
+
+// ApplyPatchWith applies a unified diff to the file. If the patch contains several files, the one
+// whose name matches the file is used. Hunks that can be applied are written to the file, even if
+// others fail. In that case errors.ErrPatchRejected is returned and, if `reject` is true,
+// the rejected hunks are written to "<file>.rej" (like patch does).
+func (f *FileObj) ApplyPatchWith(patch string, opts *diff.ApplyOptions, reject bool) (*diff.ApplyResult, error) {
+	patches, err := diff.Parse(patch)
+	if err != nil {
+		return nil, err
+	}
+	p := f.selectPatch(patches)
+	if p == nil {
+		return nil, errors.ErrInvalidPatch(0, "no patch for "+f.Name())
+	}
+	content, err := f.content()
+	if err != nil {
+		return nil, err
+	}
+	res := p.Apply(content, opts)
+	if err := f.StoreString(res.Content); err != nil {
+		return nil, err
+	}
+	rejected := res.Rejected()
+	if len(rejected) == 0 {
+		return res, nil
+	}
+	if reject {
+		if err := File(f.Path() + ".rej").StoreString(p.Reject(rejected)); err != nil {
+			return res, err
+		}
+	}
+	return res, errors.ErrPatchRejected(f.Path(), len(rejected))
+}
+
+// selectPatch returns the only patch or the one whose (new or old) name matches the file.
+func (f *FileObj) selectPatch(patches []*diff.FilePatch) *diff.FilePatch {
+	if len(patches) == 1 {
+		return patches[0]
+	}
+	path := filepath.ToSlash(f.Path())
+	for _, p := range patches {
+		for _, name := range []string{p.NewName, p.OldName} {
+			name = strings.TrimPrefix(strings.TrimPrefix(name, "a/"), "b/")
+			if name != "" && name != "/dev/null" && (path == name || strings.HasSuffix(path, "/"+name)) {
+				return p
+			}
+		}
+	}
+	return nil
+}
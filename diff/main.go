@@ -0,0 +1,182 @@
+package diff
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	OpEqual  byte = ' '
+	OpDelete byte = '-'
+	OpInsert byte = '+'
+)
+
+// Line is a line of a hunk. Text includes the line break, unless it is the last line of a file without one.
+type Line struct {
+	Op   byte
+	Text string
+}
+
+// Hunk is a group of changes with surrounding context. Line numbers start at 1,
+// if a side has no lines, its start is the line before the hunk (0 for the start of the file).
+type Hunk struct {
+	OldStart, OldLines int
+	NewStart, NewLines int
+	Lines              []Line
+}
+
+// lines returns the text of the lines that are not `skip` (i.e. the old or new side).
+func (h *Hunk) lines(skip byte) []string {
+	res := make([]string, 0, len(h.Lines))
+	for _, l := range h.Lines {
+		if l.Op != skip {
+			res = append(res, l.Text)
+		}
+	}
+	return res
+}
+
+func (h *Hunk) Old() []string { return h.lines(OpInsert) }
+func (h *Hunk) New() []string { return h.lines(OpDelete) }
+
+func (h *Hunk) String() string {
+	sb := strings.Builder{}
+	sb.WriteString("@@ -" + hunkRange(h.OldStart, h.OldLines) + " +" + hunkRange(h.NewStart, h.NewLines) + " @@\n")
+	for _, l := range h.Lines {
+		sb.WriteByte(l.Op)
+		sb.WriteString(l.Text)
+		if !strings.HasSuffix(l.Text, "\n") {
+			sb.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+	return sb.String()
+}
+
+func hunkRange(start, lines int) string {
+	if lines == 1 {
+		return strconv.Itoa(start)
+	}
+	return strconv.Itoa(start) + "," + strconv.Itoa(lines)
+}
+
+// SplitLines splits `s` into lines, keeping the line breaks.
+func SplitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	res := strings.SplitAfter(s, "\n")
+	if res[len(res)-1] == "" {
+		res = res[:len(res)-1]
+	}
+	return res
+}
+
+type edit struct {
+	op   byte
+	a, b int // index of the line in a (equal, delete) and b (equal, insert)
+}
+
+// edits returns the shortest edit script turning `a` into `b` using the linear space variant
+// of Myers' algorithm, which splits the problem at the middle snake of the shortest edit path.
+func edits(a, b []string) []edit {
+	d := &differ{a: a, b: b, res: make([]edit, 0, max(len(a), len(b)))}
+	d.compare(0, len(a), 0, len(b))
+	return d.res
+}
+
+type differ struct {
+	a, b   []string
+	vf, vb []int // reused between the steps of the recursion
+	res    []edit
+}
+
+// compare appends the edits turning a[a0:a1] into b[b0:b1].
+func (d *differ) compare(a0, a1, b0, b1 int) {
+	for a0 < a1 && b0 < b1 && d.a[a0] == d.b[b0] {
+		d.res = append(d.res, edit{OpEqual, a0, b0})
+		a0++
+		b0++
+	}
+	suf := 0
+	for a1-suf > a0 && b1-suf > b0 && d.a[a1-1-suf] == d.b[b1-1-suf] {
+		suf++
+	}
+	a1, b1 = a1-suf, b1-suf
+	switch {
+	case a0 == a1:
+		for ; b0 < b1; b0++ {
+			d.res = append(d.res, edit{OpInsert, a0, b0})
+		}
+	case b0 == b1:
+		for ; a0 < a1; a0++ {
+			d.res = append(d.res, edit{OpDelete, a0, b0})
+		}
+	default:
+		x, y, u, v := d.middleSnake(a0, a1, b0, b1)
+		d.compare(a0, x, b0, y)
+		for ; x < u; x, y = x+1, y+1 {
+			d.res = append(d.res, edit{OpEqual, x, y})
+		}
+		d.compare(u, a1, v, b1)
+	}
+	for i := suf; i > 0; i-- {
+		d.res = append(d.res, edit{OpEqual, a1 + suf - i, b1 + suf - i})
+	}
+}
+
+// middleSnake returns the start (x, y) and end (u, v) of the middle snake of the shortest edit path
+// turning a[a0:a1] into b[b0:b1]. Both ranges must be non-empty and differ in their first and last line,
+// which guarantees that both halves of the path are shorter than the entire path.
+func (d *differ) middleSnake(a0, a1, b0, b1 int) (x, y, u, v int) {
+	n, m := a1-a0, b1-b0
+	delta := n - m
+	odd := delta&1 != 0
+	maxD := (n + m + 1) / 2
+	off := maxD + 1
+	if size := 2*off + 1; len(d.vf) < size {
+		d.vf, d.vb = make([]int, size), make([]int, size)
+	}
+	vf, vb := d.vf, d.vb
+	vf[off+1], vb[off+1] = 0, 0
+	for D := 0; D <= maxD; D++ {
+		// forward paths, starting at (a0, b0)
+		for k := -D; k <= D; k += 2 {
+			var x int
+			if k == -D || (k != D && vf[off+k-1] < vf[off+k+1]) {
+				x = vf[off+k+1]
+			} else {
+				x = vf[off+k-1] + 1
+			}
+			y := x - k
+			sx, sy := x, y
+			for x < n && y < m && d.a[a0+x] == d.b[b0+y] {
+				x++
+				y++
+			}
+			vf[off+k] = x
+			if c := delta - k; odd && c >= -(D-1) && c <= D-1 && x+vb[off+c] >= n {
+				return a0 + sx, b0 + sy, a0 + x, b0 + y
+			}
+		}
+		// reverse paths, starting at (a1, b1)
+		for k := -D; k <= D; k += 2 {
+			var x int
+			if k == -D || (k != D && vb[off+k-1] < vb[off+k+1]) {
+				x = vb[off+k+1]
+			} else {
+				x = vb[off+k-1] + 1
+			}
+			y := x - k
+			sx, sy := x, y
+			for x < n && y < m && d.a[a1-1-x] == d.b[b1-1-y] {
+				x++
+				y++
+			}
+			vb[off+k] = x
+			if c := delta - k; !odd && c >= -D && c <= D && x+vf[off+c] >= n {
+				return a1 - x, b1 - y, a1 - sx, b1 - sy
+			}
+		}
+	}
+	panic("diff: no middle snake found")
+}
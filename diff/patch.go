@@ -0,0 +1,212 @@
+package diff
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/toxyl/flo/errors"
+)
+
+var reHunk = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// FilePatch are the hunks of a unified diff for a single file.
+type FilePatch struct {
+	OldName, NewName string
+	Hunks            []*Hunk
+}
+
+// headerName returns the file name of a "---" or "+++" line, without the timestamp.
+func headerName(line string) string {
+	name := strings.TrimRight(line[4:], "\r\n")
+	if i := strings.IndexByte(name, '\t'); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+func atoi(s string, def int) int {
+	if s == "" {
+		return def
+	}
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// Parse parses a unified diff, which can contain patches for several files.
+// Lines that are not part of a patch (e.g. "diff" or "index" lines) are ignored.
+func Parse(patch string) ([]*FilePatch, error) {
+	res := []*FilePatch{}
+	var cur *FilePatch
+	lines := SplitLines(patch)
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			cur = &FilePatch{OldName: headerName(line), NewName: headerName(lines[i+1])}
+			res = append(res, cur)
+			i++
+		case strings.HasPrefix(line, "@@ "):
+			m := reHunk.FindStringSubmatch(line)
+			if m == nil {
+				return nil, errors.ErrInvalidPatch(i+1, "malformed hunk header")
+			}
+			if cur == nil {
+				cur = &FilePatch{}
+				res = append(res, cur)
+			}
+			h := &Hunk{OldStart: atoi(m[1], 0), OldLines: atoi(m[2], 1), NewStart: atoi(m[3], 0), NewLines: atoi(m[4], 1)}
+			old, new := 0, 0
+			for old < h.OldLines || new < h.NewLines || (i+1 < len(lines) && strings.HasPrefix(lines[i+1], "\\")) {
+				i++
+				if i >= len(lines) {
+					return nil, errors.ErrInvalidPatch(i, "unexpected end of hunk")
+				}
+				l := lines[i]
+				if l == "\n" || l == "\r\n" {
+					l = " " + l // context lines of empty lines sometimes lose their space
+				}
+				switch l[0] {
+				case OpEqual:
+					old++
+					new++
+				case OpDelete:
+					old++
+				case OpInsert:
+					new++
+				case '\\':
+					if n := len(h.Lines); n > 0 {
+						h.Lines[n-1].Text = strings.TrimSuffix(h.Lines[n-1].Text, "\n")
+					}
+					continue
+				default:
+					return nil, errors.ErrInvalidPatch(i+1, "unexpected line in hunk")
+				}
+				if !strings.HasSuffix(l, "\n") {
+					l += "\n" // last line of the patch
+				}
+				h.Lines = append(h.Lines, Line{l[0], l[1:]})
+			}
+			if old != h.OldLines || new != h.NewLines {
+				return nil, errors.ErrInvalidPatch(i+1, "hunk doesn't match its header")
+			}
+			cur.Hunks = append(cur.Hunks, h)
+		}
+	}
+	return res, nil
+}
+
+type ApplyOptions struct {
+	// Fuzz is the maximum number of context lines that may be ignored at the start and end of a hunk.
+	Fuzz int
+}
+
+func DefaultApplyOptions() *ApplyOptions {
+	return &ApplyOptions{
+		Fuzz: 2,
+	}
+}
+
+type HunkResult struct {
+	Hunk    *Hunk
+	Applied bool
+	Offset  int // lines between the expected and the actual position
+	Fuzz    int // context lines ignored
+}
+
+type ApplyResult struct {
+	Content string
+	Hunks   []*HunkResult
+}
+
+// Rejected returns the hunks that couldn't be applied.
+func (r *ApplyResult) Rejected() []*Hunk {
+	res := []*Hunk{}
+	for _, h := range r.Hunks {
+		if !h.Applied {
+			res = append(res, h.Hunk)
+		}
+	}
+	return res
+}
+
+// trim drops up to `fuzz` context lines from the start and end of the hunk.
+// It returns the old and new lines and the number of lines dropped from the start.
+func (h *Hunk) trim(fuzz int) (old, new []string, lead int) {
+	lines := h.Lines
+	for lead < fuzz && lead < len(lines) && lines[lead].Op == OpEqual {
+		lead++
+	}
+	tail := 0
+	for tail < fuzz && tail < len(lines)-lead && lines[len(lines)-1-tail].Op == OpEqual {
+		tail++
+	}
+	t := &Hunk{Lines: lines[lead : len(lines)-tail]}
+	return t.Old(), t.New(), lead
+}
+
+// find returns the position closest to `want` (but not before `from`) where `lines` contains `old`.
+func find(lines, old []string, want, from int) (int, bool) {
+	last := len(lines) - len(old)
+	matches := func(at int) bool {
+		if at < from || at > last {
+			return false
+		}
+		for i, l := range old {
+			if lines[at+i] != l {
+				return false
+			}
+		}
+		return true
+	}
+	for dist := 0; want-dist >= from || want+dist <= last; dist++ {
+		if matches(want - dist) {
+			return want - dist, true
+		}
+		if matches(want + dist) {
+			return want + dist, true
+		}
+	}
+	return 0, false
+}
+
+// Apply applies the hunks of the patch to `content`. Hunks are searched near their expected position
+// (taking the offset of previous hunks into account) and with up to `opts.Fuzz` lines of context ignored.
+// Hunks that can't be applied are skipped, see ApplyResult.Rejected. If `opts` is nil, DefaultApplyOptions() are used.
+func (p *FilePatch) Apply(content string, opts *ApplyOptions) *ApplyResult {
+	if opts == nil {
+		opts = DefaultApplyOptions()
+	}
+	lines := SplitLines(content)
+	out := make([]string, 0, len(lines))
+	res := &ApplyResult{}
+	pos, delta := 0, 0
+	for _, h := range p.Hunks {
+		r := &HunkResult{Hunk: h}
+		res.Hunks = append(res.Hunks, r)
+		start := h.OldStart - 1
+		if h.OldLines == 0 {
+			start = h.OldStart
+		}
+		for fuzz := 0; fuzz <= opts.Fuzz && !r.Applied; fuzz++ {
+			old, new, lead := h.trim(fuzz)
+			at, ok := find(lines, old, start+lead+delta, pos)
+			if !ok {
+				continue
+			}
+			out = append(out, lines[pos:at]...)
+			out = append(out, new...)
+			pos = at + len(old)
+			r.Applied, r.Fuzz, r.Offset = true, fuzz, at-start-lead
+			delta = r.Offset
+		}
+	}
+	out = append(out, lines[pos:]...)
+	res.Content = strings.Join(out, "")
+	return res
+}
+
+// Reject returns the patch for the given hunks, e.g. to write the rejected hunks to a .rej file.
+func (p *FilePatch) Reject(hunks []*Hunk) string {
+	return (&FilePatch{OldName: p.OldName, NewName: p.NewName, Hunks: hunks}).String()
+}
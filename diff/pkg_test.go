@@ -0,0 +1,183 @@
+package diff
+
+import (
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+	a := "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\n"
+	b := "one\n2\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\neleven"
+	want := `--- a
++++ b
+@@ -1,5 +1,5 @@
+ one
+-two
++2
+ three
+ four
+ five
+@@ -8,3 +8,4 @@
+ eight
+ nine
+ ten
++eleven
+\ No newline at end of file
+`
+	if got := Unified(a, b, nil); got != want {
+		t.Errorf("unexpected diff:\n%s\nwant:\n%s", got, want)
+	}
+	if got := Unified(a, a, nil); got != "" {
+		t.Errorf("expected no diff for equal inputs, got:\n%s", got)
+	}
+	if got := Unified("", "x\n", &Options{Context: 3, LabelA: "/dev/null", LabelB: "x"}); got != "--- /dev/null\n+++ x\n@@ -0,0 +1 @@\n+x\n" {
+		t.Errorf("unexpected diff for new file:\n%s", got)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	text := func() string {
+		lines := make([]string, rnd.Intn(30))
+		for i := range lines {
+			lines[i] = string(rune('a' + rnd.Intn(5)))
+		}
+		s := strings.Join(lines, "\n")
+		if rnd.Intn(2) == 0 && s != "" {
+			s += "\n"
+		}
+		return s
+	}
+	for i := 0; i < 500; i++ {
+		a, b := text(), text()
+		for _, context := range []int{0, 1, 3} {
+			patch := Unified(a, b, &Options{Context: context, LabelA: "a", LabelB: "b"})
+			ps, err := Parse(patch)
+			if err != nil {
+				t.Fatalf("failed to parse:\n%s\n%v", patch, err)
+			}
+			if patch == "" {
+				continue
+			}
+			res := ps[0].Apply(a, nil)
+			if res.Content != b || len(res.Rejected()) > 0 {
+				t.Fatalf("round trip failed for %q -> %q:\n%s\ngot %q", a, b, patch, res.Content)
+			}
+		}
+	}
+}
+
+func TestApply_OffsetAndFuzz(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n6\n7\n"
+	patch := Unified(a, "1\n2\n3\nfour\n5\n6\n7\n", nil)
+	ps, err := Parse(patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := ps[0].Apply("0\n0\n"+a, nil)
+	if h := res.Hunks[0]; !h.Applied || h.Offset != 2 || h.Fuzz != 0 || res.Content != "0\n0\n1\n2\n3\nfour\n5\n6\n7\n" {
+		t.Errorf("expected offset 2, got %+v:\n%s", h, res.Content)
+	}
+
+	res = ps[0].Apply("1\nX\n3\n4\n5\nY\n7\n", nil)
+	if h := res.Hunks[0]; !h.Applied || h.Fuzz != 2 || res.Content != "1\nX\n3\nfour\n5\nY\n7\n" {
+		t.Errorf("expected fuzz 2, got %+v:\n%s", h, res.Content)
+	}
+
+	res = ps[0].Apply("1\n2\n3\nX\n5\n6\n7\n", nil)
+	if len(res.Rejected()) != 1 || res.Content != "1\n2\n3\nX\n5\n6\n7\n" {
+		t.Errorf("expected rejected hunk, got:\n%s", res.Content)
+	}
+	if rej := ps[0].Reject(res.Rejected()); rej != patch {
+		t.Errorf("unexpected reject output:\n%s", rej)
+	}
+}
+
+func TestParse(t *testing.T) {
+	patch := "diff -u a b\n--- a\t2024-01-01 00:00:00\n+++ b\t2024-01-01 00:00:00\n@@ -1,2 +1,2 @@\n x\n-y\n\\ No newline at end of file\n+z\n"
+	ps, err := Parse(patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ps) != 1 || ps[0].OldName != "a" || ps[0].NewName != "b" || len(ps[0].Hunks) != 1 {
+		t.Fatalf("unexpected patch %+v", ps)
+	}
+	if res := ps[0].Apply("x\ny", nil); res.Content != "x\nz\n" {
+		t.Errorf("unexpected result %q", res.Content)
+	}
+	if _, err := Parse("--- a\n+++ b\n@@ -1,2 +1,2 @@\n x\n"); err == nil {
+		t.Errorf("expected error for truncated hunk")
+	}
+}
+
+func TestEdits(t *testing.T) {
+	// lcs returns the length of the longest common subsequence, the number of equal lines of a shortest edit script
+	lcs := func(a, b []string) int {
+		dp := make([][]int, len(a)+1)
+		for i := range dp {
+			dp[i] = make([]int, len(b)+1)
+		}
+		for i := len(a) - 1; i >= 0; i-- {
+			for j := len(b) - 1; j >= 0; j-- {
+				if a[i] == b[j] {
+					dp[i][j] = dp[i+1][j+1] + 1
+				} else {
+					dp[i][j] = max(dp[i+1][j], dp[i][j+1])
+				}
+			}
+		}
+		return dp[0][0]
+	}
+	check := func(a, b []string) {
+		t.Helper()
+		i, j, equal := 0, 0, 0
+		for _, e := range edits(a, b) {
+			switch e.op {
+			case OpEqual:
+				if e.a != i || e.b != j || a[i] != b[j] {
+					t.Fatalf("%q -> %q: invalid equal edit %+v", a, b, e)
+				}
+				i, j, equal = i+1, j+1, equal+1
+			case OpDelete:
+				if e.a != i {
+					t.Fatalf("%q -> %q: invalid delete edit %+v", a, b, e)
+				}
+				i++
+			case OpInsert:
+				if e.b != j {
+					t.Fatalf("%q -> %q: invalid insert edit %+v", a, b, e)
+				}
+				j++
+			}
+		}
+		if i != len(a) || j != len(b) {
+			t.Fatalf("%q -> %q: edits end at %d/%d", a, b, i, j)
+		}
+		if want := lcs(a, b); equal != want {
+			t.Fatalf("%q -> %q: edit script isn't minimal, %d equal lines instead of %d", a, b, equal, want)
+		}
+	}
+	rnd := rand.New(rand.NewSource(1))
+	lines := func() []string {
+		res := make([]string, rnd.Intn(40))
+		for i := range res {
+			res[i] = string(rune('a' + rnd.Intn(4)))
+		}
+		return res
+	}
+	for i := 0; i < 2000; i++ {
+		check(lines(), lines())
+	}
+
+	// no common lines, the worst case for the size of the edit script
+	a, b := make([]string, 5000), make([]string, 5000)
+	for i := range a {
+		a[i], b[i] = "a"+strconv.Itoa(i), "b"+strconv.Itoa(i)
+	}
+	if es := edits(a, b); len(es) != len(a)+len(b) {
+		t.Fatalf("expected %d edits, got %d", len(a)+len(b), len(es))
+	}
+}
@@ -0,0 +1,99 @@
+package diff
+
+import "strings"
+
+type Options struct {
+	// Context is the number of unchanged lines shown around changes.
+	Context int
+	// LabelA and LabelB are used in the "---" and "+++" header lines.
+	LabelA, LabelB string
+}
+
+func DefaultOptions() *Options {
+	return &Options{
+		Context: 3,
+		LabelA:  "a",
+		LabelB:  "b",
+	}
+}
+
+// Hunks returns the changes turning `a` into `b`, grouped into hunks with `context` lines of context.
+func Hunks(a, b string, context int) []*Hunk {
+	la, lb := SplitLines(a), SplitLines(b)
+	es := edits(la, lb)
+	context = max(context, 0)
+
+	hunks := []*Hunk{}
+	for i := 0; i < len(es); {
+		if es[i].op == OpEqual {
+			i++
+			continue
+		}
+		start, last := max(0, i-context), i
+		for j := i; j < len(es); {
+			if es[j].op != OpEqual {
+				last = j
+				j++
+				continue
+			}
+			k := j
+			for k < len(es) && es[k].op == OpEqual {
+				k++
+			}
+			if k == len(es) || k-j > 2*context {
+				break
+			}
+			j = k
+		}
+		end := min(len(es), last+1+context)
+
+		h := &Hunk{OldStart: es[start].a, NewStart: es[start].b}
+		for _, e := range es[start:end] {
+			switch e.op {
+			case OpEqual:
+				h.Lines = append(h.Lines, Line{OpEqual, la[e.a]})
+				h.OldLines++
+				h.NewLines++
+			case OpDelete:
+				h.Lines = append(h.Lines, Line{OpDelete, la[e.a]})
+				h.OldLines++
+			case OpInsert:
+				h.Lines = append(h.Lines, Line{OpInsert, lb[e.b]})
+				h.NewLines++
+			}
+		}
+		if h.OldLines > 0 {
+			h.OldStart++
+		}
+		if h.NewLines > 0 {
+			h.NewStart++
+		}
+		hunks = append(hunks, h)
+		i = end
+	}
+	return hunks
+}
+
+// Unified returns the unified diff turning `a` into `b`, or an empty string if both are equal.
+// If `opts` is nil, DefaultOptions() are used.
+func Unified(a, b string, opts *Options) string {
+	if opts == nil {
+		opts = DefaultOptions()
+	}
+	hunks := Hunks(a, b, opts.Context)
+	if len(hunks) == 0 {
+		return ""
+	}
+	return (&FilePatch{OldName: opts.LabelA, NewName: opts.LabelB, Hunks: hunks}).String()
+}
+
+// String renders the patch in unified format.
+func (p *FilePatch) String() string {
+	sb := strings.Builder{}
+	sb.WriteString("--- " + p.OldName + "\n")
+	sb.WriteString("+++ " + p.NewName + "\n")
+	for _, h := range p.Hunks {
+		sb.WriteString(h.String())
+	}
+	return sb.String()
+}
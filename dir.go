@@ -9,7 +9,6 @@ import (
 
 	"github.com/toxyl/flo/errors"
 	"github.com/toxyl/flo/log"
-	"github.com/toxyl/flo/permissions"
 )
 
 type DirObj struct {
@@ -26,38 +25,61 @@ func (f *FileObj) Mkdir(mode fs.FileMode) error {
 	if err := os.MkdirAll(p, mode); err != nil && !os.IsExist(err) {
 		return err
 	}
-	defer f.updateInfo()
+	defer f.invalidate()
 	return nil
 }
 
-func (d *DirObj) Contents() *DirObj {
+// entryIsDir checks whether the entry at `path` is a directory.
+// If `follow` is true, links to directories are treated as directories.
+func entryIsDir(path string, entry fs.DirEntry, follow bool) bool {
+	if follow && entry.Type()&fs.ModeSymlink != 0 {
+		if stat, err := os.Stat(path); err == nil {
+			return stat.IsDir()
+		}
+	}
+	return entry.IsDir()
+}
+
+// list reads the directory and returns its subdirectories and files, sorted case-insensitively by name.
+// If `follow` is true, links to directories are returned as directories, otherwise as files.
+func (d *DirObj) list(follow bool) (dirs []*DirObj, files []*FileObj, err error) {
 	if !d.Permissions().IsDir() {
-		log.Error(errors.ErrIsNotDirectory(d.Path()), "Retrieving contents failed!")
-		return d
+		return nil, nil, errors.ErrIsNotDirectory(d.Path())
 	}
-	files := []*FileObj{}
-	dirs := []*DirObj{}
+	files = []*FileObj{}
+	dirs = []*DirObj{}
 	contents, err := os.ReadDir(d.Path())
 	if err != nil {
-		return d
+		return dirs, files, err
 	}
-	for _, file := range contents {
-		path := filepath.Join(d.Path(), file.Name())
-		p := permissions.New(path)
-		if p.IsDir() {
-			dirs = append(dirs, Dir(path))
+	for _, entry := range contents {
+		path := filepath.Join(d.Path(), entry.Name())
+		f := newFileFromEntry(path, entry)
+		if entryIsDir(path, entry, follow) {
+			dirs = append(dirs, f.AsDir())
 			continue
 		}
-		files = append(files, File(path))
+		files = append(files, f)
 	}
-	d.dirs = dirs
-	d.files = files
-	sort.Slice(d.dirs, func(i, j int) bool {
-		return strings.ToLower(d.dirs[i].Name()) < strings.ToLower(d.dirs[j].Name())
+	sort.Slice(dirs, func(i, j int) bool {
+		return strings.ToLower(dirs[i].Name()) < strings.ToLower(dirs[j].Name())
 	})
-	sort.Slice(d.files, func(i, j int) bool {
-		return strings.ToLower(d.files[i].Name()) < strings.ToLower(d.files[j].Name())
+	sort.Slice(files, func(i, j int) bool {
+		return strings.ToLower(files[i].Name()) < strings.ToLower(files[j].Name())
 	})
+	return dirs, files, nil
+}
+
+func (d *DirObj) Contents() *DirObj {
+	dirs, files, err := d.list(true)
+	if err != nil {
+		if !d.Permissions().IsDir() {
+			log.Error(err, "Retrieving contents failed!")
+		}
+		return d
+	}
+	d.dirs = dirs
+	d.files = files
 	return d
 }
 
@@ -69,18 +91,21 @@ func (d *DirObj) Files() []*FileObj {
 	return d.files
 }
 
-func (d *DirObj) walk(fnFile func(f *FileObj), fnDir func(d *DirObj), depth, maxDepth int) {
+func (d *DirObj) walk(fnFile func(f *FileObj), fnDir func(d *DirObj), depth, maxDepth int, anc *ancestors) {
 	if maxDepth >= 0 && depth > maxDepth {
 		return
 	}
 
 	contents := d.Contents()
+	anc = anc.push(d.Path())
 
 	for _, dir := range contents.Dirs() {
 		if fnDir != nil {
 			fnDir(dir)
 		}
-		dir.walk(fnFile, fnDir, depth+1, maxDepth)
+		if anc.check(dir) == nil {
+			dir.walk(fnFile, fnDir, depth+1, maxDepth, anc)
+		}
 	}
 
 	for _, file := range contents.Files() {
@@ -91,11 +116,11 @@ func (d *DirObj) walk(fnFile func(f *FileObj), fnDir func(d *DirObj), depth, max
 }
 
 func (d *DirObj) Each(fnFile func(f *FileObj), fnDir func(d *DirObj)) {
-	d.walk(fnFile, fnDir, 0, -1)
+	d.walk(fnFile, fnDir, 0, -1, nil)
 }
 
 func (d *DirObj) EachLimit(fnFile func(f *FileObj), fnDir func(d *DirObj), maxDepth int) {
-	d.walk(fnFile, fnDir, 0, maxDepth)
+	d.walk(fnFile, fnDir, 0, maxDepth, nil)
 }
 
 func newDir(path string) *DirObj {
@@ -108,3 +133,12 @@ func newDir(path string) *DirObj {
 }
 
 func Dir(path string) *DirObj { return newDir(path) }
+
+// AsDir returns the file as directory without reading its info again.
+func (f *FileObj) AsDir() *DirObj {
+	return &DirObj{
+		FileObj: f,
+		dirs:    []*DirObj{},
+		files:   []*FileObj{},
+	}
+}
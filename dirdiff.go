@@ -0,0 +1,321 @@
+package flo
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	c "github.com/toxyl/flo/codec"
+	"github.com/toxyl/flo/config"
+	"github.com/toxyl/flo/permissions"
+	"github.com/toxyl/flo/utils"
+	"github.com/toxyl/glog"
+)
+
+// ChangeKind is a bitmask of the ways an entry differs between two directories.
+type ChangeKind int
+
+const (
+	ChangeAdded    ChangeKind = 1 << iota // only exists in the second directory
+	ChangeRemoved                         // only exists in the first directory
+	ChangeType                            // the type (e.g. file vs. directory) differs
+	ChangeModified                        // the content (or link target) differs
+	ChangeMode                            // the permissions differ
+	ChangeOwner                           // the owner or group differ
+)
+
+var changeKindNames = []string{"added", "removed", "type", "modified", "mode", "owner"}
+
+func (k ChangeKind) Has(kind ChangeKind) bool { return k&kind != 0 }
+
+// String returns the names of all kinds, separated by commas.
+func (k ChangeKind) String() string {
+	names := []string{}
+	for i, name := range changeKindNames {
+		if k.Has(1 << i) {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+func (k ChangeKind) MarshalJSON() ([]byte, error) {
+	names := []string{}
+	for i, name := range changeKindNames {
+		if k.Has(1 << i) {
+			names = append(names, name)
+		}
+	}
+	return json.Marshal(names)
+}
+
+// Change is an entry that differs between two directories.
+type Change struct {
+	Path string     `json:"path"` // slash-separated and relative to the directories
+	Kind ChangeKind `json:"changes"`
+	A    *FileObj   `json:"-"` // nil if added
+	B    *FileObj   `json:"-"` // nil if removed
+}
+
+type DiffOptions struct {
+	// Walk selects the entries that are compared (e.g. using Filter and IgnoreFiles). If nil, all entries are.
+	Walk *WalkOptions
+	// Algorithm used to compare contents, if nil config.ChecksumAlgorithm is used.
+	Algorithm *c.Codec
+	// Quick considers files with the same size and modification time equal without hashing them.
+	Quick bool
+	// Mode reports changed permissions.
+	Mode bool
+	// Owner reports changed owners and groups.
+	Owner bool
+}
+
+func DefaultDiffOptions() *DiffOptions {
+	return &DiffOptions{
+		Walk:      nil,
+		Algorithm: nil,
+		Quick:     false,
+		Mode:      true,
+		Owner:     true,
+	}
+}
+
+type DirDiff struct {
+	A       *DirObj   `json:"-"`
+	B       *DirObj   `json:"-"`
+	Changes []*Change `json:"changes"` // sorted by path
+}
+
+// Empty returns true if both directories are the same.
+func (d *DirDiff) Empty() bool { return len(d.Changes) == 0 }
+
+// Filter returns the changes that have any of the given kinds.
+func (d *DirDiff) Filter(kind ChangeKind) []*Change {
+	res := []*Change{}
+	for _, ch := range d.Changes {
+		if ch.Kind.Has(kind) {
+			res = append(res, ch)
+		}
+	}
+	return res
+}
+
+func (d *DirDiff) Added() []*Change        { return d.Filter(ChangeAdded) }
+func (d *DirDiff) Removed() []*Change      { return d.Filter(ChangeRemoved) }
+func (d *DirDiff) Modified() []*Change     { return d.Filter(ChangeModified) }
+func (d *DirDiff) ModeChanged() []*Change  { return d.Filter(ChangeMode) }
+func (d *DirDiff) OwnerChanged() []*Change { return d.Filter(ChangeOwner) }
+func (d *DirDiff) TypeChanged() []*Change  { return d.Filter(ChangeType) }
+
+// sign returns the marker used by the renderers.
+func (ch *Change) sign() string {
+	switch {
+	case ch.Kind.Has(ChangeAdded):
+		return "+"
+	case ch.Kind.Has(ChangeRemoved):
+		return "-"
+	case ch.Kind.Has(ChangeType):
+		return "T"
+	case ch.Kind.Has(ChangeModified):
+		return "M"
+	}
+	return "~" // only metadata
+}
+
+// String renders the changes as plain text, one "<sign> <path> (<kinds>)" line per change.
+// The sign is + for added, - for removed, T for type changed, M for modified and ~ for metadata changes.
+func (d *DirDiff) String() string {
+	sb := strings.Builder{}
+	for _, ch := range d.Changes {
+		sb.WriteString(ch.sign() + " " + ch.Path)
+		if !ch.Kind.Has(ChangeAdded | ChangeRemoved) {
+			sb.WriteString(" (" + ch.Kind.String() + ")")
+		}
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// JSON renders the changes as JSON.
+func (d *DirDiff) JSON() string { return c.JSON.EncodeString(d) }
+
+// Colored renders the changes like FileInfo.String does, using the info of the second directory
+// (or the first for removed entries) and the relative path as name. The sign is colored.
+func (d *DirDiff) Colored() string {
+	maxLenOwner, maxLenGroup := 0, 0
+	for _, ch := range d.Changes {
+		f := ch.file()
+		maxLenOwner = max(maxLenOwner, len(f.Owner()))
+		maxLenGroup = max(maxLenGroup, len(f.Group()))
+	}
+	sb := utils.NewString()
+	for _, ch := range d.Changes {
+		sign := ch.sign()
+		switch sign {
+		case "+":
+			sign = glog.WrapGreen(sign)
+		case "-":
+			sign = glog.WrapRed(sign)
+		case "T":
+			sign = glog.WrapOrange(sign)
+		case "M":
+			sign = glog.WrapYellow(sign)
+		default:
+			sign = glog.WrapCyan(sign)
+		}
+		info := *ch.file().Info()
+		info.Name = ch.Path
+		line := sign + " " + info.String(maxLenOwner, maxLenGroup)
+		if !ch.Kind.Has(ChangeAdded | ChangeRemoved) {
+			line += glog.WrapGray("(" + ch.Kind.String() + ")")
+		}
+		if !config.ColorMode {
+			line = glog.StripANSI(line)
+		}
+		sb.Str(line + "\n")
+	}
+	return sb.String()
+}
+
+// file returns the file that represents the change.
+func (ch *Change) file() *FileObj {
+	if ch.B != nil {
+		return ch.B
+	}
+	return ch.A
+}
+
+// diffEntries returns the entries below `d` by slash-separated relative path.
+func diffEntries(d *DirObj, opts *WalkOptions) (map[string]*FileObj, error) {
+	res := map[string]*FileObj{}
+	for f, err := range d.Walk(opts) {
+		if err != nil {
+			return nil, err
+		}
+		rel, err := relPath(d.Path(), f.Path())
+		if err != nil {
+			continue
+		}
+		res[rel] = f
+	}
+	return res, nil
+}
+
+// compare returns how `a` and `b` differ.
+func (o *DiffOptions) compare(a, b *FileObj) (ChangeKind, error) {
+	var kind ChangeKind
+	ta, tb := a.Type(), b.Type()
+	if ta != tb {
+		return ChangeType, nil
+	}
+	switch ta {
+	case TypeSymlink, TypeDanglingLink:
+		la, err := a.Readlink()
+		if err != nil {
+			return 0, err
+		}
+		lb, err := b.Readlink()
+		if err != nil {
+			return 0, err
+		}
+		if la != lb {
+			kind |= ChangeModified
+		}
+		return kind, nil // links have no mode or owner of their own
+	case TypeFile:
+		if a.Size() != b.Size() {
+			kind |= ChangeModified
+		} else if !o.Quick || !a.LastModified().Equal(b.LastModified()) {
+			algo := o.Algorithm
+			if algo == nil {
+				algo = config.ChecksumAlgorithm
+			}
+			sa, err := a.Digest(algo)
+			if err != nil {
+				return 0, err
+			}
+			sb, err := b.Digest(algo)
+			if err != nil {
+				return 0, err
+			}
+			if !slices.Equal(sa, sb) {
+				kind |= ChangeModified
+			}
+		}
+	}
+	if o.Mode && permissions.Unix(a.meta().Mode) != permissions.Unix(b.meta().Mode) {
+		kind |= ChangeMode
+	}
+	oa, ob := a.meta().Ownership, b.meta().Ownership
+	if o.Owner && (oa.UID() != ob.UID() || oa.GID() != ob.GID()) {
+		kind |= ChangeOwner
+	}
+	return kind, nil
+}
+
+// DiffDirs compares the trees below `a` and `b`. Entries below added or removed directories are not
+// listed individually. If `opts` is nil, DefaultDiffOptions() are used.
+func DiffDirs(a, b *DirObj, opts *DiffOptions) (*DirDiff, error) {
+	if opts == nil {
+		opts = DefaultDiffOptions()
+	}
+	ea, err := diffEntries(a, opts.Walk)
+	if err != nil {
+		return nil, err
+	}
+	eb, err := diffEntries(b, opts.Walk)
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0, len(ea)+len(eb))
+	for p := range ea {
+		paths = append(paths, p)
+	}
+	for p := range eb {
+		if _, ok := ea[p]; !ok {
+			paths = append(paths, p)
+		}
+	}
+	// sort by segments, so that the entries below a directory directly follow it
+	slices.SortFunc(paths, func(x, y string) int {
+		return strings.Compare(strings.ReplaceAll(x, "/", "\x00"), strings.ReplaceAll(y, "/", "\x00"))
+	})
+
+	diff := &DirDiff{A: a, B: b, Changes: []*Change{}}
+	gone := "" // last added or removed directory
+	for _, p := range paths {
+		if gone != "" && strings.HasPrefix(p, gone+"/") {
+			continue
+		}
+		fa, fb := ea[p], eb[p]
+		ch := &Change{Path: p, A: fa, B: fb}
+		switch {
+		case fb == nil:
+			ch.Kind = ChangeRemoved
+		case fa == nil:
+			ch.Kind = ChangeAdded
+		default:
+			if ch.Kind, err = opts.compare(fa, fb); err != nil {
+				return nil, err
+			}
+		}
+		if ch.Kind == 0 {
+			continue
+		}
+		if ch.Kind.Has(ChangeAdded|ChangeRemoved|ChangeType) && ((fa != nil && fa.IsDir()) || (fb != nil && fb.IsDir())) {
+			gone = p
+		}
+		diff.Changes = append(diff.Changes, ch)
+	}
+	return diff, nil
+}
+
+// relPath returns the slash-separated path of `file` relative to `dir`.
+func relPath(dir, file string) (string, error) {
+	rel, err := filepath.Rel(dir, file)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(rel), nil
+}
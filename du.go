@@ -0,0 +1,194 @@
+package flo
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/toxyl/flo/errors"
+	"github.com/toxyl/glog"
+)
+
+type DiskUsageOptions struct {
+	// Workers is the number of directories read concurrently, defaults to the number of CPUs.
+	Workers int
+	// MaxDepth limits the depth of the per-child breakdown, 0 only breaks down the contents of the
+	// directory itself. Everything below is still counted. Use a negative value for the entire tree.
+	MaxDepth int
+	// OneFileSystem skips directories on other filesystems than the one of the directory itself (like du -x).
+	OneFileSystem bool
+	// CountLinks counts hardlinked files every time they are found, by default they are counted once (like du -l).
+	CountLinks bool
+}
+
+func DefaultDiskUsageOptions() *DiskUsageOptions {
+	return &DiskUsageOptions{
+		Workers:       runtime.NumCPU(),
+		MaxDepth:      -1,
+		OneFileSystem: false,
+		CountLinks:    false,
+	}
+}
+
+// DiskUsage is the usage of a file or directory, for directories it includes everything below them.
+type DiskUsage struct {
+	Path      string
+	Name      string
+	IsDir     bool
+	Size      int64        // apparent size in bytes
+	Allocated int64        // bytes allocated on disk
+	Files     int64        // number of files (including links, devices, etc.), including the entry itself
+	Dirs      int64        // number of directories, including the entry itself
+	Children  []*DiskUsage // direct children sorted by name, nil below opts.MaxDepth
+}
+
+func (u *DiskUsage) add(c *DiskUsage) {
+	u.Size += c.Size
+	u.Allocated += c.Allocated
+	u.Files += c.Files
+	u.Dirs += c.Dirs
+}
+
+// Sort sorts the children (recursively) by allocated size, largest first.
+func (u *DiskUsage) Sort() *DiskUsage {
+	sort.SliceStable(u.Children, func(i, j int) bool { return u.Children[i].Allocated > u.Children[j].Allocated })
+	for _, c := range u.Children {
+		c.Sort()
+	}
+	return u
+}
+
+func (u *DiskUsage) String() string {
+	return glog.PadLeft(glog.HumanReadableBytesIEC(u.Allocated), 12, ' ') + " " +
+		glog.PadLeft(glog.HumanReadableBytesIEC(u.Size), 12, ' ') + " " + u.Path
+}
+
+// diskID identifies an inode.
+type diskID struct {
+	dev, ino uint64
+}
+
+type diskStat struct {
+	id        diskID
+	links     uint64
+	allocated int64
+}
+
+type duWalker struct {
+	opts *DiskUsageOptions
+	dev  uint64
+	sem  chan struct{}
+	mu   sync.Mutex
+	seen map[diskID]struct{}
+	errs []error
+}
+
+func (w *duWalker) fail(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.errs = append(w.errs, err)
+}
+
+// first returns true if the inode has not been counted yet.
+func (w *duWalker) first(id diskID) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.seen[id]; ok {
+		return false
+	}
+	w.seen[id] = struct{}{}
+	return true
+}
+
+func (w *duWalker) entry(path string, info fs.FileInfo) *DiskUsage {
+	u := &DiskUsage{Path: path, Name: info.Name(), IsDir: info.IsDir()}
+	if u.IsDir {
+		u.Dirs = 1
+	} else {
+		u.Files = 1
+	}
+	st := diskStatOf(info)
+	if !u.IsDir && !w.opts.CountLinks && st.links > 1 && !w.first(st.id) {
+		return u // hardlink that has already been counted
+	}
+	u.Size = info.Size()
+	u.Allocated = st.allocated
+	return u
+}
+
+func (w *duWalker) dir(u *DiskUsage, depth int) {
+	entries, err := os.ReadDir(u.Path)
+	if err != nil {
+		w.fail(errors.ErrFailedToReadFile(u.Path, err))
+	}
+	children := make([]*DiskUsage, 0, len(entries))
+	wg := sync.WaitGroup{}
+	for _, entry := range entries {
+		path := filepath.Join(u.Path, entry.Name())
+		info, err := entry.Info()
+		if err != nil {
+			w.fail(errors.ErrFailedToReadFile(path, err))
+			continue
+		}
+		if info.IsDir() && w.opts.OneFileSystem && diskStatOf(info).id.dev != w.dev {
+			continue
+		}
+		c := w.entry(path, info)
+		children = append(children, c)
+		if !c.IsDir {
+			continue
+		}
+		select {
+		case w.sem <- struct{}{}:
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-w.sem }()
+				w.dir(c, depth+1)
+			}()
+		default:
+			w.dir(c, depth+1) // all workers are busy
+		}
+	}
+	wg.Wait()
+	for _, c := range children {
+		u.add(c)
+	}
+	if w.opts.MaxDepth < 0 || depth <= w.opts.MaxDepth {
+		u.Children = children
+	}
+}
+
+// DiskUsage returns the usage of the directory and everything below it, computed concurrently.
+// If the directory is a link, it is followed. Links below it are counted but not followed.
+// If `opts` is nil, DefaultDiskUsageOptions() are used.
+//
+// Entries that can't be read are skipped and reported in the returned error,
+// the usage of everything else is still returned.
+func (d *DirObj) DiskUsage(opts *DiskUsageOptions) (*DiskUsage, error) {
+	if opts == nil {
+		opts = DefaultDiskUsageOptions()
+	}
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	info, err := os.Stat(d.Path())
+	if err != nil {
+		return nil, errors.ErrFailedToReadFile(d.Path(), err)
+	}
+	w := &duWalker{
+		opts: opts,
+		dev:  diskStatOf(info).id.dev,
+		sem:  make(chan struct{}, workers-1), // the calling goroutine is a worker too
+		seen: map[diskID]struct{}{},
+	}
+	u := w.entry(d.Path(), info)
+	if u.IsDir {
+		w.dir(u, 0)
+	}
+	return u, errors.Combine(w.errs...)
+}
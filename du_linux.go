@@ -0,0 +1,21 @@
+//go:build linux
+
+package flo
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// diskStatOf returns the inode, link count and allocated bytes of `info`.
+func diskStatOf(info fs.FileInfo) diskStat {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return diskStat{allocated: info.Size()}
+	}
+	return diskStat{
+		id:        diskID{dev: uint64(stat.Dev), ino: stat.Ino},
+		links:     uint64(stat.Nlink),
+		allocated: stat.Blocks * 512, // st_blocks is always in 512 byte units
+	}
+}
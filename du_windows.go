@@ -0,0 +1,10 @@
+//go:build windows
+
+package flo
+
+import "io/fs"
+
+// diskStatOf returns the apparent size as allocated size, inodes and links are not available on windows.
+func diskStatOf(info fs.FileInfo) diskStat {
+	return diskStat{allocated: info.Size()}
+}
@@ -0,0 +1,319 @@
+package flo
+
+import (
+	"bytes"
+	"cmp"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	c "github.com/toxyl/flo/codec"
+	"github.com/toxyl/flo/config"
+	"github.com/toxyl/flo/errors"
+)
+
+type DuplicateOptions struct {
+	// Walk selects the files that are compared (e.g. using Filter and IgnoreFiles). If nil, all files are.
+	Walk *WalkOptions
+	// Dirs are additional trees to search, files are compared across all of them.
+	Dirs []*DirObj
+	// MinSize ignores smaller files, defaults to 1 to ignore empty files.
+	MinSize int64
+	// PartialSize is the number of bytes hashed to rule out files of the same size before hashing them entirely.
+	PartialSize int64
+	// Algorithm used to compare contents, if nil config.ChecksumAlgorithm is used.
+	Algorithm *c.Codec
+}
+
+func DefaultDuplicateOptions() *DuplicateOptions {
+	return &DuplicateOptions{
+		Walk:        nil,
+		Dirs:        nil,
+		MinSize:     1,
+		PartialSize: 4 * KiB,
+		Algorithm:   nil,
+	}
+}
+
+// DuplicateGroup are files with identical content.
+type DuplicateGroup struct {
+	Size  int64
+	Sum   string
+	Files []*FileObj // sorted by path, the first one is kept when deduplicating
+
+	algo   *c.Codec             // used to compare the contents
+	stamps map[string]fileStamp // of the files when they were found, by path
+}
+
+// fileStamp is the size and modification time of a file, used to detect changes.
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+func stampOf(info os.FileInfo) fileStamp {
+	return fileStamp{size: info.Size(), modTime: info.ModTime()}
+}
+
+func (s fileStamp) matches(info os.FileInfo) bool {
+	return info.Size() == s.size && info.ModTime().Equal(s.modTime)
+}
+
+// Wasted returns the number of bytes used by all but one of the files.
+func (g *DuplicateGroup) Wasted() int64 { return g.Size * int64(len(g.Files)-1) }
+
+type Duplicates struct {
+	Groups []*DuplicateGroup // sorted by wasted bytes, largest first
+}
+
+// Wasted returns the number of bytes that can be saved by deduplicating all groups.
+func (d *Duplicates) Wasted() int64 {
+	var n int64
+	for _, g := range d.Groups {
+		n += g.Wasted()
+	}
+	return n
+}
+
+// Count returns the number of files that are duplicates of another file.
+func (d *Duplicates) Count() int {
+	n := 0
+	for _, g := range d.Groups {
+		n += len(g.Files) - 1
+	}
+	return n
+}
+
+// partialSum hashes the first `n` bytes of the file.
+func (f *FileObj) partialSum(algo *c.Codec, n int64) ([]byte, error) {
+	file, err := f.TryOpenReadOnly()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	h := algo.Hash()
+	if _, err := io.CopyN(h, file, n); err != nil && err != io.EOF {
+		return nil, errors.ErrFailedToReadFile(f.Path(), err)
+	}
+	return h.Sum(nil), nil
+}
+
+// group splits `files` into groups by key, groups with a single file are dropped.
+func group(files []*FileObj, key func(f *FileObj) (string, error), errs *[]error) map[string][]*FileObj {
+	groups := map[string][]*FileObj{}
+	for _, f := range files {
+		k, err := key(f)
+		if err != nil {
+			*errs = append(*errs, err)
+			continue
+		}
+		groups[k] = append(groups[k], f)
+	}
+	for k, g := range groups {
+		if len(g) < 2 {
+			delete(groups, k)
+		}
+	}
+	return groups
+}
+
+// FindDuplicates returns groups of files with identical content below this directory (and `opts.Dirs`).
+// Candidates are grouped by size first, then by a hash of their first bytes and finally by a hash of
+// their entire content. Hardlinks to the same file are not considered duplicates.
+// If `opts` is nil, DefaultDuplicateOptions() are used.
+//
+// Files that can't be read are skipped and reported in the returned error.
+func (d *DirObj) FindDuplicates(opts *DuplicateOptions) (*Duplicates, error) {
+	if opts == nil {
+		opts = DefaultDuplicateOptions()
+	}
+	algo := opts.Algorithm
+	if algo == nil {
+		algo = config.ChecksumAlgorithm
+	}
+	if algo.Hash == nil {
+		return nil, errors.ErrChecksumAlgorithmInvalid(algo.Name)
+	}
+
+	errs := []error{}
+	seen := map[diskID]bool{}
+	stamps := map[string]fileStamp{}
+	bySize := map[int64][]*FileObj{}
+	for _, dir := range append([]*DirObj{d}, opts.Dirs...) {
+		for f, err := range dir.Walk(opts.Walk) {
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if f.Type() != TypeFile || f.Size() < opts.MinSize {
+				continue
+			}
+			info, err := os.Lstat(f.Path())
+			if err != nil {
+				errs = append(errs, errors.ErrFailedToReadFile(f.Path(), err))
+				continue
+			}
+			if id := diskStatOf(info).id; id != (diskID{}) {
+				if seen[id] {
+					continue // hardlink to a file we already have
+				}
+				seen[id] = true
+			}
+			// Dedupe compares against these, because the metadata of f might be reloaded in between
+			stamps[f.Path()] = stampOf(info)
+			bySize[info.Size()] = append(bySize[info.Size()], f)
+		}
+	}
+
+	res := &Duplicates{Groups: []*DuplicateGroup{}}
+	for size, files := range bySize {
+		if len(files) < 2 {
+			continue
+		}
+		candidates := map[string][]*FileObj{"": files}
+		if size > opts.PartialSize && opts.PartialSize > 0 {
+			candidates = group(files, func(f *FileObj) (string, error) {
+				sum, err := f.partialSum(algo, opts.PartialSize)
+				return string(sum), err
+			}, &errs)
+		}
+		for _, files := range candidates {
+			for sum, g := range group(files, func(f *FileObj) (string, error) {
+				sum, err := f.Digest(algo)
+				return algo.Format(sum), err
+			}, &errs) {
+				slices.SortFunc(g, func(a, b *FileObj) int { return strings.Compare(a.Path(), b.Path()) })
+				gs := map[string]fileStamp{}
+				for _, f := range g {
+					gs[f.Path()] = stamps[f.Path()]
+				}
+				res.Groups = append(res.Groups, &DuplicateGroup{Size: size, Sum: sum, Files: g, algo: algo, stamps: gs})
+			}
+		}
+	}
+	slices.SortFunc(res.Groups, func(a, b *DuplicateGroup) int {
+		if n := cmp.Compare(b.Wasted(), a.Wasted()); n != 0 {
+			return n
+		}
+		return strings.Compare(a.Files[0].Path(), b.Files[0].Path())
+	})
+	return res, errors.Combine(errs...)
+}
+
+// DedupeAction is how duplicates are replaced.
+type DedupeAction int
+
+const (
+	DedupeHardlink DedupeAction = iota // replace duplicates with hardlinks to the kept file
+	DedupeSymlink                      // replace duplicates with relative symlinks to the kept file
+	DedupeReflink                      // replace duplicates with copy-on-write clones (linux, e.g. btrfs or XFS)
+)
+
+func (a DedupeAction) String() string {
+	switch a {
+	case DedupeHardlink:
+		return "hardlink"
+	case DedupeSymlink:
+		return "symlink"
+	case DedupeReflink:
+		return "reflink"
+	}
+	return "unknown"
+}
+
+// DedupeOp is the replacement of a single duplicate.
+type DedupeOp struct {
+	Action DedupeAction
+	File   *FileObj // the duplicate that is replaced
+	Target *FileObj // the kept file
+	Saved  int64
+	Err    error
+}
+
+func (op *DedupeOp) String() string {
+	s := op.Action.String() + " " + op.File.Path() + " -> " + op.Target.Path() + " (" + strconv.FormatInt(op.Saved, 10) + " bytes)"
+	if op.Err != nil {
+		s += ": " + op.Err.Error()
+	}
+	return s
+}
+
+// Dedupe replaces all but the first file of each group using `action`. If `dryRun` is true, nothing
+// is changed and the returned ops show what would be done. Files whose size or modification time
+// changed since they were found are skipped. Right before a file is replaced, its content is compared
+// again using the algorithm the groups were found with. Every file is replaced atomically,
+// the errors of all ops are combined.
+func (d *Duplicates) Dedupe(action DedupeAction, dryRun bool) ([]*DedupeOp, error) {
+	ops := []*DedupeOp{}
+	errs := []error{}
+	for _, g := range d.Groups {
+		algo := g.algo
+		if algo == nil {
+			algo = config.ChecksumAlgorithm
+		}
+		target := g.Files[0]
+		for _, f := range g.Files[1:] {
+			op := &DedupeOp{Action: action, File: f, Target: target, Saved: g.Size}
+			ops = append(ops, op)
+			if dryRun {
+				continue
+			}
+			stamp, ok := g.stamps[f.Path()]
+			if !ok {
+				// not found by FindDuplicates, so there is nothing to compare against
+				stamp = fileStamp{size: -1}
+			}
+			if op.Err = f.replaceWith(target, action, algo, stamp); op.Err != nil {
+				op.Saved = 0
+				errs = append(errs, op.Err)
+			}
+		}
+	}
+	return ops, errors.Combine(errs...)
+}
+
+// replaceWith atomically replaces the file with a link to or a clone of `target`. It fails if the file
+// doesn't match `found` (unless its size is -1) or its content differs from `target` when hashed with `algo`.
+func (f *FileObj) replaceWith(target *FileObj, action DedupeAction, algo *c.Codec, found fileStamp) error {
+	stat, err := os.Lstat(f.Path())
+	if err != nil {
+		return errors.ErrFailedToReadFile(f.Path(), err)
+	}
+	if !stat.Mode().IsRegular() || (found.size >= 0 && !found.matches(stat)) {
+		return errors.ErrFileChanged(f.Path())
+	}
+	tmp := filepath.Join(filepath.Dir(f.Path()), "."+f.Name()+".dedupe-"+strconv.FormatInt(time.Now().UnixNano(), 36))
+	switch action {
+	case DedupeHardlink:
+		err = os.Link(target.Path(), tmp)
+	case DedupeSymlink:
+		rel, e := filepath.Rel(filepath.Dir(f.Path()), target.Path())
+		if e != nil {
+			rel = target.Path()
+		}
+		err = os.Symlink(rel, tmp)
+	case DedupeReflink:
+		err = reflink(target.Path(), tmp, stat.Mode().Perm())
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return errors.ErrFailedToCreateFile(f.Path(), err)
+	}
+	// make sure the content is still the same right before replacing the file
+	a, errA := f.Refresh().Digest(algo)
+	b, errB := target.Digest(algo)
+	if errA != nil || errB != nil || !bytes.Equal(a, b) {
+		os.Remove(tmp)
+		return errors.ErrFileChanged(f.Path())
+	}
+	if err := os.Rename(tmp, f.Path()); err != nil {
+		os.Remove(tmp)
+		return errors.ErrFailedToRenameFile(tmp, f.Path(), err)
+	}
+	f.invalidate()
+	return nil
+}
@@ -0,0 +1,30 @@
+//go:build linux
+
+package flo
+
+import (
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// ficlone is the FICLONE ioctl, _IOW(0x94, 9, int).
+const ficlone = 0x40049409
+
+// reflink creates `dst` as copy-on-write clone of `src`, this fails if the filesystem doesn't support it.
+func reflink(src, dst string, perm fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, out.Fd(), ficlone, in.Fd()); errno != 0 {
+		return errno
+	}
+	return out.Chmod(perm) // not affected by the umask
+}
@@ -0,0 +1,11 @@
+//go:build windows
+
+package flo
+
+import (
+	"errors"
+	"io/fs"
+)
+
+// reflink is not supported on windows.
+func reflink(src, dst string, perm fs.FileMode) error { return errors.ErrUnsupported }
@@ -6,8 +6,24 @@ import (
 	"strings"
 
 	"github.com/toxyl/errors"
+	ferrors "github.com/toxyl/flo/errors"
+	"github.com/toxyl/flo/glob"
 )
 
+// embeddedFilter checks the path (relative to the extraction root) against all `filters`.
+// It returns whether the entry should be extracted and, for directories, whether to skip it entirely.
+func embeddedFilter(rel string, isDir bool, filters []*glob.Filter) (extract bool, skip bool) {
+	for _, f := range filters {
+		if f.Excluded(rel) {
+			return false, isDir
+		}
+		if !isDir && !f.Included(rel) {
+			return false, false
+		}
+	}
+	return true, false
+}
+
 // InitWithEmbeddedFS unpacks the given `embeddedFS` into this directory.
 //
 // It will first remove the directory if it exists and then create it
@@ -20,36 +36,48 @@ import (
 // Be aware that embedded filesystems do not store file permissions.
 // Therefore all dirs and files will be written with the provided
 // permissions `dirMode` and `fileMode` respectively.
-func (dir *DirObj) InitWithEmbeddedFS(embeddedFS embed.FS, pathPrefix string, dirMode, fileMode fs.FileMode) error {
+//
+// Optionally `filters` can be given to only extract matching files,
+// their patterns are matched against the paths with the prefix stripped.
+func (dir *DirObj) InitWithEmbeddedFS(embeddedFS embed.FS, pathPrefix string, dirMode, fileMode fs.FileMode, filters ...*glob.Filter) error {
 	if dir.Exists() {
 		// remove first, so we start with clean data
 		if err := dir.Remove(); err != nil {
-			return errors.Newf("removing base dir %s failed", dir.Path()).Append(err)
+			return err
 		}
 	}
 	if err := dir.Mkdir(dirMode); err != nil {
-		return errors.Newf("creating base dir %s failed", dir.Path()).Append(err)
+		return ferrors.ErrFailedToCreateDir(dir.Path(), err)
 	}
 	pathPrefix += "/"
 	err := fs.WalkDir(embeddedFS, ".", func(path string, file fs.DirEntry, err error) error {
+		if err != nil {
+			return ferrors.ErrFailedToReadFile(path, err)
+		}
 		if strings.HasPrefix(path, pathPrefix) {
-			dst := dir.Dir(strings.TrimPrefix(path, pathPrefix))
-			if file.IsDir() {
-				if err := dst.Mkdir(dirMode); err != nil {
-					return errors.Newf("creating dir %s failed", dst.Path()).Append(err)
-				}
+			rel := strings.TrimPrefix(path, pathPrefix)
+			if extract, skip := embeddedFilter(rel, file.IsDir(), filters); skip {
+				return fs.SkipDir
+			} else if !extract {
 				return nil
 			}
+			if file.IsDir() {
+				return nil // created with the first file below it, so filters don't leave empty dirs behind
+			}
+			dst := dir.Dir(rel)
+			if err := dst.Parent().Mkdir(dirMode); err != nil {
+				return ferrors.ErrFailedToCreateDir(dst.Parent().Path(), err)
+			}
 
 			data, err := embeddedFS.ReadFile(path)
 			if err != nil {
-				return errors.Newf("reading %s failed", path).Append(err)
+				return ferrors.ErrFailedToReadFile(path, err)
 			}
 			if err := dst.StoreBytes(data); err != nil {
-				return errors.Newf("writing %s failed", dst.Path()).Append(err)
+				return err
 			}
 			if err := dst.Perm(fileMode); err != nil {
-				return errors.Newf("setting permissions on %s failed", dst.Path()).Append(err)
+				return ferrors.ErrFailedToSetPermissions(dst.Path(), fileMode, err)
 			}
 		}
 		return nil
@@ -63,35 +91,44 @@ func (dir *DirObj) InitWithEmbeddedFS(embeddedFS embed.FS, pathPrefix string, di
 // UpdateFromEmbeddedFS works similar to InitWithEmbeddedFS but will not clear the directory before extracting the embedded FS.
 //
 // Existing files will only be overwritten with the version in the embedded FS if you set `overwriteExisting` to `true`.
-func (dir *DirObj) UpdateFromEmbeddedFS(embeddedFS embed.FS, pathPrefix string, dirMode, fileMode fs.FileMode, overwriteExisting bool) error {
+func (dir *DirObj) UpdateFromEmbeddedFS(embeddedFS embed.FS, pathPrefix string, dirMode, fileMode fs.FileMode, overwriteExisting bool, filters ...*glob.Filter) error {
 	if !dir.Exists() {
-		return dir.InitWithEmbeddedFS(embeddedFS, pathPrefix, dirMode, fileMode)
+		return dir.InitWithEmbeddedFS(embeddedFS, pathPrefix, dirMode, fileMode, filters...)
 	}
 	pathPrefix += "/"
 	err := fs.WalkDir(embeddedFS, ".", func(path string, file fs.DirEntry, err error) error {
+		if err != nil {
+			return ferrors.ErrFailedToReadFile(path, err)
+		}
 		if strings.HasPrefix(path, pathPrefix) {
-			dst := dir.Dir(strings.TrimPrefix(path, pathPrefix))
+			rel := strings.TrimPrefix(path, pathPrefix)
+			if extract, skip := embeddedFilter(rel, file.IsDir(), filters); skip {
+				return fs.SkipDir
+			} else if !extract {
+				return nil
+			}
+			if file.IsDir() {
+				return nil // created with the first file below it, so filters don't leave empty dirs behind
+			}
+			dst := dir.Dir(rel)
 			if dst.Exists() && !overwriteExisting {
 				return nil // silently ignore this file
 			}
-			if file.IsDir() {
-				if err := dst.Mkdir(dirMode); err != nil {
-					return errors.Newf("creating dir %s failed", dst.Path()).Append(err)
-				}
-				return nil
+			if err := dst.Parent().Mkdir(dirMode); err != nil {
+				return ferrors.ErrFailedToCreateDir(dst.Parent().Path(), err)
 			}
 			data, err := embeddedFS.ReadFile(path)
 			if err != nil {
-				return errors.Newf("reading %s failed", path).Append(err)
+				return ferrors.ErrFailedToReadFile(path, err)
 			}
 			if err := dst.Remove(); err != nil {
-				return errors.Newf("removing %s failed", dst.Path()).Append(err)
+				return err
 			}
 			if err := dst.StoreBytes(data); err != nil {
-				return errors.Newf("writing %s failed", dst.Path()).Append(err)
+				return err
 			}
 			if err := dst.Perm(fileMode); err != nil {
-				return errors.Newf("setting permissions on %s failed", dst.Path()).Append(err)
+				return ferrors.ErrFailedToSetPermissions(dst.Path(), fileMode, err)
 			}
 		}
 		return nil
@@ -21,13 +21,41 @@ var (
 	ErrFailedToCreateDir      = func(file string, err error) error { return ErrFile("create dir", file, err) }
 	ErrFailedToCreateFile     = func(file string, err error) error { return ErrFile("create file", file, err) }
 	ErrFailedToDeleteFile     = func(file string, err error) error { return ErrFile("delete", file, err) }
+	ErrFailedToReadFile       = func(file string, err error) error { return ErrFile("read", file, err) }
+	ErrFailedToWriteFile      = func(file string, err error) error { return ErrFile("write", file, err) }
+	ErrFailedToSyncFile       = func(file string, err error) error { return ErrFile("sync", file, err) }
 	ErrFailedToCopyFile       = func(src, dst string, err error) error { return ErrFile(fmt.Sprintf("copy %s to", src), dst, err) }
+	ErrFailedToRenameFile     = func(src, dst string, err error) error { return ErrFile(fmt.Sprintf("rename %s to", src), dst, err) }
+	ErrFailedToSetOwner       = func(file string, err error) error { return ErrFile("set owner of", file, err) }
+	ErrFailedToReadLink       = func(file string, err error) error { return ErrFile("read link", file, err) }
+	ErrFailedToExec           = func(file string, err error) error { return ErrFile("execute", file, err) }
+	ErrInterpreterNotFound    = func(name string, err error) error { return ErrFile("find interpreter", name, err) }
 	ErrFailedToSetPermissions = func(file string, mode fs.FileMode, err error) error {
 		return ErrFile(fmt.Sprintf("set %s permissions on", mode.String()), file, err)
 	}
 	ErrIsNotExecutable = func(file string) error {
 		return errors.Newf("%s is not an executable, use PermExec(o, g, w) first", file)
 	}
-	ErrIsNotDirectory = func(file string) error { return errors.Newf("%s is not a directory", file) }
-	ErrMustBePointer  = func(target any) error { return errors.Newf("expected *%T, but got %T", target, target) }
+	ErrIsNotDirectory     = func(file string) error { return errors.Newf("%s is not a directory", file) }
+	ErrSymlinkCycle       = func(file string) error { return errors.Newf("%s links to one of its parent directories", file) }
+	ErrInvalidPermissions = func(expr string) error { return errors.Newf("%s is not a valid permission expression", expr) }
+	ErrInvalidPattern     = func(pattern string, err error) error { return errors.Newf("invalid pattern %s", pattern).Append(err) }
+	ErrUnsupportedTarget  = func(target any) error { return errors.Newf("unsupported target type %T", target) }
+	ErrExecResult         = func(result string) error { return errors.Newf("%s", result) }
+	ErrPipelineFailed     = func(pipeline string, err error) error { return errors.Newf("pipeline %s failed", pipeline).Append(err) }
+	ErrFileChanged        = func(file string) error { return errors.Newf("%s has been changed in the meantime", file) }
+	ErrInvalidPatch       = func(line int, msg string) error { return errors.Newf("invalid patch, line %d: %s", line, msg) }
+	ErrPatchRejected      = func(file string, hunks int) error {
+		return errors.Newf("%d hunks could not be applied to %s", hunks, file)
+	}
+	ErrMustBePointer = func(target any) error { return errors.Newf("expected *%T, but got %T", target, target) }
 )
+
+// Combine merges all non-nil `errs` into a single error, nil is returned if there are none.
+func Combine(errs ...error) error {
+	res := errors.New(errs...)
+	if res.Len() == 0 {
+		return nil
+	}
+	return res
+}
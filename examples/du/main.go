@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/toxyl/flo"
+)
+
+func main() {
+	if len(os.Args) < 2 || len(os.Args) > 3 {
+		fmt.Println("Prints the disk usage (allocated and apparent size) of the given directory and its children up to the given maximum depth, largest first.")
+		fmt.Println("Usage: " + filepath.Base(os.Args[0]) + " [directory] <maximum depth>")
+		return
+	}
+	depth := uint64(0)
+	if len(os.Args) == 3 {
+		m, err := strconv.ParseUint(os.Args[2], 10, 31)
+		if err != nil {
+			fmt.Println("Second argument must be a valid unsigned integer")
+			return
+		}
+		depth = m
+	}
+
+	opts := flo.DefaultDiskUsageOptions()
+	opts.MaxDepth = int(depth)
+	usage, err := flo.Dir(os.Args[1]).DiskUsage(opts)
+	if usage == nil {
+		fmt.Println(err)
+		return
+	}
+	printUsage(usage.Sort())
+	fmt.Printf("%d files, %d directories\n", usage.Files, usage.Dirs)
+}
+
+func printUsage(u *flo.DiskUsage) {
+	for _, c := range u.Children {
+		if c.IsDir {
+			printUsage(c)
+		}
+	}
+	fmt.Println(u)
+}
@@ -1,22 +1,33 @@
 package flo
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/toxyl/flo/errors"
 )
 
 func (f *FileObj) prepExecCommand(args ...any) (*exec.Cmd, error) {
-	if !f.IsExecutable() {
-		return nil, errors.ErrIsNotExecutable(f.Path())
+	path, strs, err := f.execCommand(nil, execArgs(args...))
+	if err != nil {
+		return nil, err
 	}
+	return exec.Command(path, strs...), nil
+}
+
+func execArgs(args ...any) []string {
 	strs := []string{}
 	for _, a := range args {
-		strs = append(strs, fmt.Sprintf("%s", a))
+		strs = append(strs, fmt.Sprint(a))
 	}
-	return exec.Command(f.Path(), strs...), nil
+	return strs
 }
 
 func (f *FileObj) Exec(args ...any) error {
@@ -46,10 +57,327 @@ func (f *FileObj) ExecQuiet(args ...any) error {
 	return cmd.Run()
 }
 
-func (f *FileObj) ExecBackground(args ...any) error {
-	cmd, err := f.prepExecCommand(args...)
+// ExecBackground starts the file without waiting for it to finish, use the returned Process to manage it.
+func (f *FileObj) ExecBackground(args ...any) (*Process, error) {
+	return f.Start(context.Background(), NewExecOptions(args...).WithCapture(false))
+}
+
+// ExecOptions configure ExecWith, use NewExecOptions and the builder methods to create them.
+type ExecOptions struct {
+	Args []string
+	// Dir is the working directory, the current one is used if empty.
+	Dir string
+	// CleanEnv starts with an empty environment instead of the one of the current process.
+	CleanEnv bool
+	// Setenv adds or overwrites environment variables.
+	Setenv map[string]string
+	// Unsetenv removes environment variables.
+	Unsetenv []string
+	// Stdin is read by the process, if nil it reads from the null device.
+	Stdin io.Reader
+	// StdinFile is opened and read by the process, it takes precedence over Stdin.
+	StdinFile *FileObj
+	// Stdout and Stderr additionally receive the output as it is written.
+	Stdout, Stderr io.Writer
+	// Capture stores stdout and stderr in the ExecResult.
+	Capture bool
+	// Timeout stops the process after the given duration, 0 disables it.
+	Timeout time.Duration
+	// Grace is the time a process gets to exit after it has been asked to terminate
+	// (because of the timeout or a cancelled context) before it is killed, 0 kills it right away.
+	// With ProcessGroup, the entire group is killed once the grace period is over.
+	Grace time.Duration
+	// ProcessGroup starts the process in its own process group, signals are sent to the entire group (linux only).
+	ProcessGroup bool
+	// Restart decides whether Start restarts the process after it has exited.
+	Restart RestartPolicy
+	// RestartDelay is the time to wait before restarting the process.
+	RestartDelay time.Duration
+	// MaxRestarts limits the number of restarts, 0 means unlimited.
+	MaxRestarts int
+	// PidFile makes Start write the pid to a file named like the executable with a .pid extension.
+	PidFile bool
+	// Sandbox restricts the process, nil means no restrictions.
+	Sandbox *Sandbox
+	// Stream makes the output of processes created with Start available via Process.Stdout and Process.Stderr.
+	Stream bool
+}
+
+func NewExecOptions(args ...any) *ExecOptions {
+	return &ExecOptions{
+		Args:     execArgs(args...),
+		Dir:      "",
+		CleanEnv: false,
+		Setenv:   map[string]string{},
+		Unsetenv: []string{},
+		Stdin:    nil,
+		Capture:  true,
+		Timeout:  0,
+		Grace:    5 * time.Second,
+
+		ProcessGroup: false,
+		Restart:      RestartNever,
+		RestartDelay: time.Second,
+		MaxRestarts:  0,
+		PidFile:      false,
+		Stream:       false,
+		Sandbox:      nil,
+	}
+}
+
+func (o *ExecOptions) WithArgs(args ...any) *ExecOptions {
+	o.Args = append(o.Args, execArgs(args...)...)
+	return o
+}
+func (o *ExecOptions) InDir(dir string) *ExecOptions { o.Dir = dir; return o }
+func (o *ExecOptions) WithCleanEnv() *ExecOptions    { o.CleanEnv = true; return o }
+func (o *ExecOptions) WithEnv(key, value string) *ExecOptions {
+	o.Setenv[key] = value
+	return o
+}
+func (o *ExecOptions) WithoutEnv(keys ...string) *ExecOptions {
+	o.Unsetenv = append(o.Unsetenv, keys...)
+	return o
+}
+func (o *ExecOptions) WithStdin(r io.Reader) *ExecOptions       { o.Stdin = r; return o }
+func (o *ExecOptions) WithStdinFile(f *FileObj) *ExecOptions    { o.StdinFile = f; return o }
+func (o *ExecOptions) WithStdout(w io.Writer) *ExecOptions      { o.Stdout = w; return o }
+func (o *ExecOptions) WithStderr(w io.Writer) *ExecOptions      { o.Stderr = w; return o }
+func (o *ExecOptions) WithCapture(capture bool) *ExecOptions    { o.Capture = capture; return o }
+func (o *ExecOptions) WithTimeout(d time.Duration) *ExecOptions { o.Timeout = d; return o }
+func (o *ExecOptions) WithGrace(d time.Duration) *ExecOptions   { o.Grace = d; return o }
+func (o *ExecOptions) WithProcessGroup() *ExecOptions           { o.ProcessGroup = true; return o }
+func (o *ExecOptions) WithPidFile() *ExecOptions                { o.PidFile = true; return o }
+func (o *ExecOptions) WithStream() *ExecOptions                 { o.Stream = true; return o }
+func (o *ExecOptions) WithSandbox(s *Sandbox) *ExecOptions      { o.Sandbox = s; return o }
+func (o *ExecOptions) WithRestart(policy RestartPolicy, delay time.Duration, max int) *ExecOptions {
+	o.Restart, o.RestartDelay, o.MaxRestarts = policy, delay, max
+	return o
+}
+
+// env returns the environment of the process.
+func (o *ExecOptions) env() []string {
+	env := []string{}
+	if !o.CleanEnv {
+		env = os.Environ()
+	}
+	env = slices.DeleteFunc(env, func(kv string) bool {
+		k, _, _ := strings.Cut(kv, "=")
+		_, set := o.Setenv[k]
+		return set || slices.Contains(o.Unsetenv, k)
+	})
+	keys := make([]string, 0, len(o.Setenv))
+	for k := range o.Setenv {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		env = append(env, k+"="+o.Setenv[k])
+	}
+	return env
+}
+
+// output returns a writer for an output stream that writes to `w` and, if capturing, to `buf`.
+func (o *ExecOptions) output(w io.Writer, buf *bytes.Buffer) io.Writer {
+	switch {
+	case o.Capture && w != nil:
+		return io.MultiWriter(buf, w)
+	case o.Capture:
+		return buf
+	}
+	return w
+}
+
+// ExecResult describes a finished process.
+type ExecResult struct {
+	Path     string
+	Args     []string
+	ExitCode int       // -1 if the process was killed by a signal or couldn't be started
+	Signal   os.Signal // the signal that killed the process, nil if it exited
+	TimedOut bool      // the process was stopped because of the timeout
+	Duration time.Duration
+	Stdout   []byte // only set if output is captured
+	Stderr   []byte // only set if output is captured
+	// UserTime and SystemTime are the CPU times used by the process.
+	UserTime, SystemTime time.Duration
+	// MaxRSS is the peak memory usage in bytes (linux only).
+	MaxRSS int64
+}
+
+func (r *ExecResult) Success() bool { return r.ExitCode == 0 }
+
+func (r *ExecResult) String() string {
+	cmd := strings.Join(append([]string{r.Path}, r.Args...), " ")
+	switch {
+	case r.TimedOut:
+		return fmt.Sprintf("%s timed out after %s", cmd, r.Duration)
+	case r.Signal != nil:
+		return fmt.Sprintf("%s was stopped by signal (%s) after %s", cmd, r.Signal, r.Duration)
+	}
+	return fmt.Sprintf("%s exited with %d after %s", cmd, r.ExitCode, r.Duration)
+}
+
+// ExecWith runs the file using `opts` and waits for it to finish. If `opts` is nil, NewExecOptions() are used.
+// When `ctx` is cancelled or the timeout expires, the process is asked to terminate (SIGTERM) and killed
+// if it is still running after the grace period.
+// If the file isn't executable but starts with a shebang, its interpreter is run with the file instead.
+//
+// The result is returned whenever the process has been started. The error is set if the process
+// couldn't be started, didn't exit successfully or was stopped.
+func (f *FileObj) ExecWith(ctx context.Context, opts *ExecOptions) (*ExecResult, error) {
+	if opts == nil {
+		opts = NewExecOptions()
+	}
+	r, err := f.startCmd(ctx, opts)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	return r.wait()
+}
+
+// minWaitDelay is the time to wait for the output of a process that has exited or been killed,
+// if the grace period is 0.
+const minWaitDelay = 100 * time.Millisecond
+
+// execRun is a started command.
+type execRun struct {
+	file           *FileObj
+	opts           *ExecOptions
+	cmd            *exec.Cmd
+	ctx            context.Context
+	cancel         context.CancelFunc
+	stdout, stderr *bytes.Buffer
+	stdin          *os.File
+	start          time.Time
+	waited         chan struct{} // closed once cmd.Wait has returned
+}
+
+// startCmd starts the file using `opts`, the returned run has to be waited for.
+func (f *FileObj) startCmd(ctx context.Context, opts *ExecOptions) (*execRun, error) {
+	path, args, err := f.execCommand(opts.Sandbox, opts.Args)
+	if err != nil {
+		return nil, err
+	}
+	r := &execRun{
+		file:   f,
+		opts:   opts,
+		ctx:    ctx,
+		cancel: func() {},
+		stdout: &bytes.Buffer{},
+		stderr: &bytes.Buffer{},
+		waited: make(chan struct{}),
+	}
+	if opts.Timeout > 0 {
+		r.ctx, r.cancel = context.WithTimeout(ctx, opts.Timeout)
+	}
+	cmd := exec.CommandContext(r.ctx, path, args...)
+	cmd.Dir = opts.Dir
+	cmd.Env = opts.env()
+	cmd.Stdin = opts.Stdin
+	cmd.Stdout = opts.output(opts.Stdout, r.stdout)
+	cmd.Stderr = opts.output(opts.Stderr, r.stderr)
+	cmd.Cancel = func() error { return r.stop(opts.Grace) }
+	// stops waiting for the output once the grace period is over, in case children that weren't killed
+	// keep it open (0 would wait forever)
+	cmd.WaitDelay = max(opts.Grace, minWaitDelay)
+	if opts.ProcessGroup {
+		setProcessGroup(cmd)
+	}
+	start := cmd.Start
+	if opts.Sandbox != nil {
+		if err := opts.Sandbox.apply(cmd); err != nil {
+			r.cancel()
+			return nil, errors.ErrFailedToExec(f.Path(), err)
+		}
+		start = func() error { return opts.Sandbox.start(cmd) }
+	}
+	if opts.StdinFile != nil {
+		in, err := opts.StdinFile.TryOpenReadOnly()
+		if err != nil {
+			r.cancel()
+			return nil, err
+		}
+		cmd.Stdin = in
+		r.stdin = in
+	}
+	r.cmd = cmd
+	r.start = time.Now()
+	if err := start(); err != nil {
+		r.close()
+		return nil, errors.ErrFailedToExec(f.Path(), err)
+	}
+	return r, nil
+}
+
+// stop asks the process to terminate and kills it if it is still running after `grace`, if `grace`
+// is 0 it is killed right away. With a process group, the group is killed after `grace` even if the
+// process itself has exited, so children that ignore the request don't survive.
+func (r *execRun) stop(grace time.Duration) error {
+	if r.exited() {
+		return nil // the pid might already belong to another process
+	}
+	p, group := r.cmd.Process, r.opts.ProcessGroup
+	if grace <= 0 {
+		return kill(p, group)
+	}
+	err := terminate(p, group)
+	go func() {
+		t := time.NewTimer(grace)
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-r.waited:
+			if !group {
+				return
+			}
+			// members of the group might still be running after the process itself has exited
+			<-t.C
+		}
+		_ = kill(p, group)
+	}()
+	return err
+}
+
+// exited returns true once the process has been waited for.
+func (r *execRun) exited() bool {
+	select {
+	case <-r.waited:
+		return true
+	default:
+		return false
+	}
+}
+
+func (r *execRun) close() {
+	r.cancel()
+	if r.stdin != nil {
+		r.stdin.Close()
+	}
+}
+
+// wait waits for the command to finish and returns the result.
+func (r *execRun) wait() (*ExecResult, error) {
+	defer r.close()
+	err := r.cmd.Wait()
+	close(r.waited)
+	res := &ExecResult{
+		Path:     r.file.Path(),
+		Args:     r.opts.Args,
+		ExitCode: -1,
+		Duration: time.Since(r.start),
+		TimedOut: r.opts.Timeout > 0 && r.ctx.Err() == context.DeadlineExceeded,
+	}
+	if r.opts.Capture {
+		res.Stdout, res.Stderr = r.stdout.Bytes(), r.stderr.Bytes()
+	}
+	if state := r.cmd.ProcessState; state != nil {
+		res.ExitCode = state.ExitCode()
+		res.Signal = exitSignal(state)
+		res.UserTime, res.SystemTime = state.UserTime(), state.SystemTime()
+		res.MaxRSS = maxRSS(state)
+	}
+	if err != nil || !res.Success() {
+		return res, errors.ErrFailedToExec(r.file.Path(), errors.ErrExecResult(res.String()))
 	}
-	return cmd.Start()
+	return res, nil
 }
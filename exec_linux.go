@@ -0,0 +1,51 @@
+//go:build linux
+
+package flo
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// terminate asks the process (or its process group) to exit.
+func terminate(p *os.Process, group bool) error { return signal(p, syscall.SIGTERM, group) }
+
+// kill kills the process (or its process group).
+func kill(p *os.Process, group bool) error { return signal(p, syscall.SIGKILL, group) }
+
+// signal sends `sig` to the process, if `group` is true to its entire process group.
+func signal(p *os.Process, sig os.Signal, group bool) error {
+	s, ok := sig.(syscall.Signal)
+	if !group || !ok {
+		return p.Signal(sig)
+	}
+	if err := syscall.Kill(-p.Pid, s); err != nil {
+		return p.Signal(sig)
+	}
+	return nil
+}
+
+// setProcessGroup makes the command start in its own process group.
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// exitSignal returns the signal that killed the process or nil if it exited.
+func exitSignal(state *os.ProcessState) os.Signal {
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return ws.Signal()
+	}
+	return nil
+}
+
+// maxRSS returns the peak memory usage of the process in bytes.
+func maxRSS(state *os.ProcessState) int64 {
+	if ru, ok := state.SysUsage().(*syscall.Rusage); ok {
+		return ru.Maxrss * 1024 // reported in KiB
+	}
+	return 0
+}
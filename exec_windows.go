@@ -0,0 +1,26 @@
+//go:build windows
+
+package flo
+
+import (
+	"os"
+	"os/exec"
+)
+
+// terminate kills the process, windows can't ask a process to exit.
+func terminate(p *os.Process, group bool) error { return p.Kill() }
+
+// kill kills the process, process groups are not supported on windows.
+func kill(p *os.Process, group bool) error { return p.Kill() }
+
+// signal sends `sig` to the process, only os.Kill is supported on windows.
+func signal(p *os.Process, sig os.Signal, group bool) error { return p.Signal(sig) }
+
+// setProcessGroup does nothing, process groups are not supported on windows.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// exitSignal always returns nil, there are no signals on windows.
+func exitSignal(state *os.ProcessState) os.Signal { return nil }
+
+// maxRSS is not available on windows.
+func maxRSS(state *os.ProcessState) int64 { return 0 }
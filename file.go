@@ -5,38 +5,41 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/toxyl/flo/checksum"
-	"github.com/toxyl/flo/config"
 	"github.com/toxyl/flo/errors"
 	"github.com/toxyl/flo/log"
-	"github.com/toxyl/flo/ownership"
 	"github.com/toxyl/flo/permissions"
 	"github.com/toxyl/flo/utils"
 )
 
 type FileObj struct {
-	path string
-	info *FileInfo
+	mu     sync.Mutex
+	path   string
+	entry  fs.DirEntry // set when found via os.ReadDir, used to load the info
+	info   *FileInfo
+	loaded bool
 }
 
 func (f *FileObj) Name() string                          { return filepath.Base(f.path) }
 func (f *FileObj) BaseDir() string                       { return filepath.Dir(f.path) }
 func (f *FileObj) Path() string                          { return f.path }
-func (f *FileObj) Size() int64                           { return f.info.Size }
-func (f *FileObj) Owner() string                         { return f.info.Ownership.User() }
-func (f *FileObj) Group() string                         { return f.info.Ownership.Group() }
-func (f *FileObj) LastModified() time.Time               { return f.info.LastModified }
-func (f *FileObj) FileMode() fs.FileMode                 { return f.info.Permissions.FileMode() }
-func (f *FileObj) Permissions() *permissions.Permissions { return f.info.Permissions }
-func (f *FileObj) Checksum() *checksum.Checksum          { f.info.Checksum.Update(); return f.info.Checksum }
-func (f *FileObj) Info() *FileInfo                       { f.updateInfo(); return f.info }
-func (f *FileObj) Exists() bool                          { return f.info.Exists }
+func (f *FileObj) Size() int64                           { return f.meta().Size }
+func (f *FileObj) Owner() string                         { return f.meta().Ownership.User() }
+func (f *FileObj) Group() string                         { return f.meta().Ownership.Group() }
+func (f *FileObj) LastModified() time.Time               { return f.meta().LastModified }
+func (f *FileObj) FileMode() fs.FileMode                 { return f.meta().Permissions.FileMode() }
+func (f *FileObj) Permissions() *permissions.Permissions { return f.meta().Permissions }
+func (f *FileObj) Checksum() *checksum.Checksum          { f.meta().Checksum.Update(); return f.meta().Checksum }
+func (f *FileObj) Info() *FileInfo                       { return f.meta() }
+func (f *FileObj) Exists() bool                          { return f.meta().Exists }
+func (f *FileObj) IsDir() bool                           { return f.meta().Permissions.IsDir() }
 func (f *FileObj) Depth() int                            { return strings.Count(f.Path(), string(filepath.Separator)) }
-func (f *FileObj) NewerThan(t time.Time) bool            { return f.info.NewerThan(t) }
-func (f *FileObj) OlderThan(t time.Time) bool            { return f.info.OlderThan(t) }
-func (f *FileObj) String(lenOwner, lenGroup int) string  { return f.info.String(lenOwner, lenGroup) }
+func (f *FileObj) NewerThan(t time.Time) bool            { return f.meta().NewerThan(t) }
+func (f *FileObj) OlderThan(t time.Time) bool            { return f.meta().OlderThan(t) }
+func (f *FileObj) String(lenOwner, lenGroup int) string  { return f.meta().String(lenOwner, lenGroup) }
 func (f *FileObj) Mkparent(perm fs.FileMode) error       { return f.Parent().Mkdir(perm) }
 func (f *FileObj) Create(perm fs.FileMode) error {
 	file, err := os.Create(f.Path())
@@ -57,51 +60,72 @@ func (f *FileObj) Mklink(path string) error {
 	return os.Symlink(f.Path(), fSymlink.Path())
 }
 
-func (f *FileObj) Open() (file *os.File, closer func()) {
-	file, err := os.OpenFile(f.Path(), os.O_RDWR, 0644)
-	if log.Error(err, "could not open file %s", f.Path()) {
-		return nil, nil
+// OpenFile opens the file with the given `flag` (e.g. os.O_RDWR|os.O_CREATE) and `perm`.
+// Unlike the Open* functions it returns the error instead of logging it.
+func (f *FileObj) OpenFile(flag int, perm fs.FileMode) (*os.File, error) {
+	file, err := os.OpenFile(f.Path(), flag, perm)
+	if err != nil {
+		return nil, errors.ErrFailedToOpenFile(f.Path(), err)
 	}
-	return file, func() { file.Close() }
+	return file, nil
 }
 
-func (f *FileObj) OpenReadOnly() (file *os.File, closer func()) {
-	file, err := os.OpenFile(f.Path(), os.O_RDONLY, 0644)
+// TryOpen opens the file for reading and writing.
+func (f *FileObj) TryOpen() (*os.File, error) { return f.OpenFile(os.O_RDWR, 0644) }
+
+// TryOpenReadOnly opens the file for reading.
+func (f *FileObj) TryOpenReadOnly() (*os.File, error) { return f.OpenFile(os.O_RDONLY, 0644) }
+
+// TryOpenWriteOnly opens the file for writing.
+func (f *FileObj) TryOpenWriteOnly() (*os.File, error) { return f.OpenFile(os.O_WRONLY, 0644) }
+
+// TryOpenAppend opens the file for appending, creating the file if it doesn't exist.
+func (f *FileObj) TryOpenAppend() (*os.File, error) {
+	return f.OpenFile(os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+}
+
+// TryOpenTruncate opens the file for writing, creating the file if it doesn't exist. The file will be truncated if it exists.
+func (f *FileObj) TryOpenTruncate() (*os.File, error) {
+	return f.OpenFile(os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+}
+
+func (f *FileObj) openLogged(fn func() (*os.File, error)) (file *os.File, closer func()) {
+	file, err := fn()
 	if log.Error(err, "could not open file %s", f.Path()) {
-		return nil, nil
+		return nil, func() {}
 	}
 	return file, func() { file.Close() }
 }
 
+// Open opens the file for reading and writing.
+// Errors are logged, use TryOpen if you want to handle them yourself.
+func (f *FileObj) Open() (file *os.File, closer func()) { return f.openLogged(f.TryOpen) }
+
+// OpenReadOnly opens the file for reading.
+// Errors are logged, use TryOpenReadOnly if you want to handle them yourself.
+func (f *FileObj) OpenReadOnly() (file *os.File, closer func()) {
+	return f.openLogged(f.TryOpenReadOnly)
+}
+
+// OpenWriteOnly opens the file for writing.
+// Errors are logged, use TryOpenWriteOnly if you want to handle them yourself.
 func (f *FileObj) OpenWriteOnly() (file *os.File, closer func()) {
-	file, err := os.OpenFile(f.Path(), os.O_WRONLY, 0644)
-	if log.Error(err, "could not open file %s", f.Path()) {
-		return nil, nil
-	}
-	return file, func() { file.Close() }
+	return f.openLogged(f.TryOpenWriteOnly)
 }
 
 // OpenAppend opens the file for appending, creating the file if it doesn't exist.
-func (f *FileObj) OpenAppend() (file *os.File, closer func()) {
-	file, err := os.OpenFile(f.Path(), os.O_CREATE|os.O_APPEND, 0644)
-	if log.Error(err, "could not open file %s", f.Path()) {
-		return nil, nil
-	}
-	return file, func() { file.Close() }
-}
+// Errors are logged, use TryOpenAppend if you want to handle them yourself.
+func (f *FileObj) OpenAppend() (file *os.File, closer func()) { return f.openLogged(f.TryOpenAppend) }
 
 // OpenTruncate opens the file for writing, creating the file if it doesn't exist. The file will be truncated if it exists.
+// Errors are logged, use TryOpenTruncate if you want to handle them yourself.
 func (f *FileObj) OpenTruncate() (file *os.File, closer func()) {
-	file, err := os.OpenFile(f.Path(), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
-	if log.Error(err, "could not open file %s", f.Path()) {
-		return nil, nil
-	}
-	return file, func() { file.Close() }
+	return f.openLogged(f.TryOpenTruncate)
 }
 
 func (f *FileObj) Remove() error {
-	defer f.updateInfo()
 	err := os.RemoveAll(f.Path())
+	f.invalidate()
 	if f.Exists() {
 		return errors.ErrFailedToDeleteFile(f.Path(), err)
 	}
@@ -110,34 +134,30 @@ func (f *FileObj) Remove() error {
 
 func (f *FileObj) Copy(destinationPath string) error {
 	fp := f.Parent()
-	fp.updateInfo()
-	f.updateInfo()
+	f.Refresh()
 	return utils.FileCopy(f.Path(), destinationPath, fp.FileMode(), f.FileMode())
 }
 
 func (f *FileObj) CopyFrom(file *FileObj) error {
-	defer f.updateInfo()
+	defer f.invalidate()
 	return file.Copy(f.Path())
 }
 
 func newFile(path string) *FileObj {
 	pabs, _ := filepath.Abs(path)
 	f := &FileObj{
-		path: pabs,
-		info: &FileInfo{
-			Name:         path,
-			Mode:         0,
-			LastModified: time.Time{},
-			Exists:       false,
-			Size:         0,
-			Checksum:     checksum.New(config.ChecksumAlgorithm, path),
-			Path:         path,
-			Permissions:  permissions.New(path),
-			Ownership:    ownership.New(path),
-		},
+		path:   pabs,
+		entry:  nil,
+		info:   nil,
+		loaded: false,
 	}
-	f.updateInfo()
+	return f
+}
 
+// newFileFromEntry creates a file from an entry returned by os.ReadDir.
+func newFileFromEntry(path string, entry fs.DirEntry) *FileObj {
+	f := newFile(path)
+	f.entry = entry
 	return f
 }
 
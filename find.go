@@ -0,0 +1,336 @@
+package flo
+
+import (
+	"io/fs"
+	"iter"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/toxyl/flo/errors"
+	"github.com/toxyl/flo/glob"
+	"github.com/toxyl/flo/permissions"
+)
+
+const (
+	KiB int64 = 1024
+	MiB       = 1024 * KiB
+	GiB       = 1024 * MiB
+	TiB       = 1024 * GiB
+)
+
+type FileType int
+
+const (
+	TypeFile FileType = iota
+	TypeDir
+	TypeSymlink
+	TypeFIFO
+	TypeSocket
+	TypeBlockDevice
+	TypeCharDevice
+	TypeDanglingLink
+)
+
+// Predicate decides whether a file matches a query.
+type Predicate func(f *FileObj) bool
+
+// Query is a find(1)-like query over a directory tree. All conditions added to a query must match,
+// use Or and Not to build other combinations. Depths follow find(1) semantics, i.e. the
+// contents of the searched directory have depth 1. Unlike find(1), the searched directory itself
+// is never matched, so MaxDepth(0) matches nothing and MinDepth(0) is the same as MinDepth(1).
+type Query struct {
+	dir      *DirObj
+	opts     *WalkOptions
+	minDepth int
+	maxDepth int
+	pred     Predicate
+}
+
+// Where adds a custom condition to the query.
+func (q *Query) Where(fn Predicate) *Query {
+	prev := q.pred
+	q.pred = func(f *FileObj) bool { return prev(f) && fn(f) }
+	return q
+}
+
+// Or makes the query match if it or any of the `others` match.
+func (q *Query) Or(others ...*Query) *Query {
+	prev := q.pred
+	q.pred = func(f *FileObj) bool {
+		if prev(f) {
+			return true
+		}
+		for _, o := range others {
+			if o.pred(f) {
+				return true
+			}
+		}
+		return false
+	}
+	return q
+}
+
+// And makes the query match if it and all of the `others` match.
+func (q *Query) And(others ...*Query) *Query {
+	for _, o := range others {
+		q.Where(o.pred)
+	}
+	return q
+}
+
+// Not makes the query match only if `other` does not match.
+func (q *Query) Not(other *Query) *Query {
+	return q.Where(func(f *FileObj) bool { return !other.pred(f) })
+}
+
+// Type matches files of any of the given types, see FileObj.Type. Links are not followed,
+// like with find(1) TypeSymlink also matches dangling links.
+func (q *Query) Type(types ...FileType) *Query {
+	return q.Where(func(f *FileObj) bool {
+		ft := f.Type()
+		for _, t := range types {
+			if ft == t || (t == TypeSymlink && ft == TypeDanglingLink) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Name matches files whose name matches the glob `pattern`.
+func (q *Query) Name(pattern string) *Query {
+	return q.Where(func(f *FileObj) bool { return glob.Match(pattern, f.Name(), false) })
+}
+
+// IName works like Name but ignores the case.
+func (q *Query) IName(pattern string) *Query {
+	return q.Where(func(f *FileObj) bool { return glob.Match(pattern, f.Name(), true) })
+}
+
+// Path matches files whose path relative to the searched directory matches the glob `pattern`.
+// For queries without a directory, the pattern is matched against the end of the path.
+func (q *Query) Path(pattern string) *Query {
+	return q.Where(func(f *FileObj) bool {
+		if q.dir == nil {
+			return f.Match(pattern)
+		}
+		rel, err := filepath.Rel(q.dir.Path(), f.Path())
+		return err == nil && glob.Match(pattern, rel, false)
+	})
+}
+
+func (q *Query) SizeGT(n int64) *Query {
+	return q.Where(func(f *FileObj) bool { return f.Size() > n })
+}
+
+func (q *Query) SizeLT(n int64) *Query {
+	return q.Where(func(f *FileObj) bool { return f.Size() < n })
+}
+
+// Empty matches empty files and directories.
+func (q *Query) Empty() *Query {
+	return q.Where(func(f *FileObj) bool {
+		if !f.IsDir() {
+			return f.Size() == 0
+		}
+		for range f.AsDir().Entries() {
+			return false
+		}
+		return true
+	})
+}
+
+// ModifiedWithin matches files that have been modified within the last `d`.
+func (q *Query) ModifiedWithin(d time.Duration) *Query {
+	return q.Where(func(f *FileObj) bool { return f.NewerThan(time.Now().Add(-d)) })
+}
+
+func (q *Query) ModifiedBefore(t time.Time) *Query {
+	return q.Where(func(f *FileObj) bool { return f.OlderThan(t) })
+}
+
+func (q *Query) ModifiedAfter(t time.Time) *Query {
+	return q.Where(func(f *FileObj) bool { return f.NewerThan(t) })
+}
+
+// Owner matches files owned by the given user name or UID.
+func (q *Query) Owner(user string) *Query {
+	return q.Where(func(f *FileObj) bool { return f.Owner() == user || f.Info().Ownership.UID() == user })
+}
+
+// Group matches files owned by the given group name or GID.
+func (q *Query) Group(group string) *Query {
+	return q.Where(func(f *FileObj) bool { return f.Group() == group || f.Info().Ownership.GID() == group })
+}
+
+func (q *Query) Executable() *Query {
+	return q.Where(func(f *FileObj) bool { return f.IsExecutable() })
+}
+
+// PermMatches matches files whose permissions match `expr`, which uses the syntax of chmod
+// (see permissions.Parse): an octal mode (e.g. "0644") must match exactly, of the symbolic
+// clauses "o+w" requires the bits to be set, "g-x" requires them to be cleared and "u=rw"
+// requires exactly those bits. An invalid `expr` matches nothing.
+func (q *Query) PermMatches(expr string) *Query {
+	m, err := permissions.Parse(expr)
+	if err != nil {
+		return q.Where(func(f *FileObj) bool { return false })
+	}
+	return q.Where(func(f *FileObj) bool { return m.Matches(f.FileMode()) })
+}
+
+// MinDepth ignores matches less than `n` levels below the searched directory.
+func (q *Query) MinDepth(n int) *Query {
+	q.minDepth = n
+	return q
+}
+
+// MaxDepth does not descend more than `n` levels below the searched directory.
+// Since the searched directory itself is never matched, 0 matches nothing.
+func (q *Query) MaxDepth(n int) *Query {
+	q.maxDepth = n
+	return q
+}
+
+// Options sets the walk options used to traverse the directory (e.g. to use ignore files).
+// MaxDepth of the options is ignored, use MaxDepth of the query instead.
+func (q *Query) Options(opts *WalkOptions) *Query {
+	q.opts = opts
+	return q
+}
+
+// Match returns true if `f` matches the conditions of the query, depths are not considered.
+func (q *Query) Match(f *FileObj) bool { return q.pred(f) }
+
+// All returns an iterator over all matches and the errors encountered while walking.
+func (q *Query) All() iter.Seq2[*FileObj, error] {
+	return func(yield func(f *FileObj, err error) bool) {
+		if q.dir == nil || q.maxDepth == 0 {
+			return
+		}
+		opts := *DefaultWalkOptions()
+		if q.opts != nil {
+			opts = *q.opts
+		}
+		opts.MaxDepth = -1
+		if q.maxDepth >= 0 {
+			opts.MaxDepth = q.maxDepth - 1
+		}
+		root := q.dir.Path()
+		for f, err := range q.dir.Walk(&opts) {
+			if err != nil {
+				if !yield(f, err) {
+					return
+				}
+				continue
+			}
+			rel, _ := filepath.Rel(root, f.Path())
+			if strings.Count(rel, string(filepath.Separator))+1 < q.minDepth {
+				continue
+			}
+			if q.pred(f) && !yield(f, nil) {
+				return
+			}
+		}
+	}
+}
+
+// List returns all matches sorted by path. Errors don't stop the search, they are returned combined.
+func (q *Query) List() ([]*FileObj, error) {
+	res := []*FileObj{}
+	errs := []error{}
+	for f, err := range q.All() {
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		res = append(res, f)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Path() < res[j].Path() })
+	return res, errors.Combine(errs...)
+}
+
+// Count returns the number of matches.
+func (q *Query) Count() (int, error) {
+	res, err := q.List()
+	return len(res), err
+}
+
+// Each calls `fn` for every match, returning an error from `fn` stops the search.
+func (q *Query) Each(fn func(f *FileObj) error) error {
+	errs := []error{}
+	for f, err := range q.All() {
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return errors.Combine(errs...)
+}
+
+// Delete removes all matches (including the contents of matching directories) and returns them.
+func (q *Query) Delete() ([]*FileObj, error) {
+	matches, err := q.List()
+	errs := []error{err}
+	deleted := []*FileObj{}
+	// deepest first, so we don't remove a directory before its matching contents
+	for i := len(matches) - 1; i >= 0; i-- {
+		if err := matches[i].Remove(); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		deleted = append(deleted, matches[i])
+	}
+	return deleted, errors.Combine(errs...)
+}
+
+// Chmod sets the permissions of all matches to `mode`.
+func (q *Query) Chmod(mode fs.FileMode) error {
+	matches, err := q.List()
+	errs := []error{err}
+	for _, f := range matches {
+		errs = append(errs, errors.ErrFailedToSetPermissions(f.Path(), mode, f.Perm(mode)))
+	}
+	return errors.Combine(errs...)
+}
+
+// Exec runs `executable` once for every match. Arguments equal to "{}" are replaced with the path of the match.
+func (q *Query) Exec(executable *FileObj, args ...any) error {
+	matches, err := q.List()
+	errs := []error{err}
+	for _, f := range matches {
+		a := make([]any, len(args))
+		for i, arg := range args {
+			if s, ok := arg.(string); ok && s == "{}" {
+				arg = f.Path()
+			}
+			a[i] = arg
+		}
+		errs = append(errs, executable.Exec(a...))
+	}
+	return errors.Combine(errs...)
+}
+
+// NewQuery returns a query that is not bound to a directory, use it to build conditions
+// for Or, And and Not or to match single files with Match.
+func NewQuery() *Query {
+	return &Query{
+		dir:      nil,
+		opts:     nil,
+		minDepth: 0,
+		maxDepth: -1,
+		pred:     func(f *FileObj) bool { return true },
+	}
+}
+
+// Find returns a query that searches this directory.
+func (d *DirObj) Find() *Query {
+	q := NewQuery()
+	q.dir = d
+	return q
+}
@@ -0,0 +1,71 @@
+package glob
+
+// Filter combines include and exclude patterns. Patterns without a path separator
+// match the name of an entry at any depth (e.g. `*.yaml`), all others match the path
+// relative to the root of the operation (e.g. `config/**/*.yaml`).
+type Filter struct {
+	include []*Pattern
+	exclude []*Pattern
+}
+
+func compileAll(patterns []string, ignoreCase bool) ([]*Pattern, error) {
+	res := []*Pattern{}
+	for _, pattern := range patterns {
+		if !hasSeparator(pattern) {
+			pattern = "**/" + pattern
+		}
+		p, err := Compile(pattern, ignoreCase)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, p)
+	}
+	return res, nil
+}
+
+func matchAny(patterns []*Pattern, path string) bool {
+	for _, p := range patterns {
+		if p.Match(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// Included returns true if there are no include patterns or if `path` matches one of them.
+func (f *Filter) Included(path string) bool {
+	return f == nil || len(f.include) == 0 || matchAny(f.include, path)
+}
+
+// Excluded returns true if `path` or one of its parent directories matches one of the exclude patterns,
+// i.e. an excluded directory also excludes everything below it.
+func (f *Filter) Excluded(path string) bool {
+	if f == nil || len(f.exclude) == 0 {
+		return false
+	}
+	for i := range len(path) {
+		if path[i] == '/' && i > 0 && matchAny(f.exclude, path[:i]) {
+			return true
+		}
+	}
+	return matchAny(f.exclude, path)
+}
+
+// Match returns true if `path` is included and not excluded.
+func (f *Filter) Match(path string) bool {
+	return f.Included(path) && !f.Excluded(path)
+}
+
+// NewFilter compiles the given `include` and `exclude` patterns.
+// If `include` is empty, everything that is not excluded matches.
+func NewFilter(include, exclude []string, ignoreCase bool) (*Filter, error) {
+	inc, err := compileAll(include, ignoreCase)
+	if err != nil {
+		return nil, err
+	}
+	exc, err := compileAll(exclude, ignoreCase)
+	if err != nil {
+		return nil, err
+	}
+	return &Filter{include: inc, exclude: exc}, nil
+}
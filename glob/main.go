@@ -0,0 +1,109 @@
+package glob
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/toxyl/flo/errors"
+)
+
+// Pattern is a compiled glob pattern. Supported syntax:
+//
+//   - `*` matches any sequence of characters except the path separator
+//   - `**` matches any number of path segments (only as a full segment, e.g. `a/**/b`)
+//   - `?` matches a single character except the path separator
+//   - `[abc]` matches a character class, also supports ranges (`[a-z]`) and negation (`[!a]` or `[^a]`)
+//   - `{a,b}` matches alternatives, can be nested
+//   - `\x` matches the literal character x
+type Pattern struct {
+	raw        string
+	ignoreCase bool
+	alts       [][]string // brace-expanded alternatives, split into segments
+}
+
+func (p *Pattern) String() string { return p.raw }
+
+// HasDoubleStar returns true if the pattern can match at any depth.
+func (p *Pattern) HasDoubleStar() bool {
+	for _, alt := range p.alts {
+		for _, seg := range alt {
+			if seg == "**" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Match returns true if `path` matches the pattern, segment by segment.
+func (p *Pattern) Match(path string) bool {
+	segs := split(path)
+	for _, alt := range p.alts {
+		if matchSegments(alt, segs, p.ignoreCase, false) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchPrefix returns true if something below the directory `path` could match the pattern.
+// This can be used to prune directories while walking.
+func (p *Pattern) MatchPrefix(path string) bool {
+	segs := split(path)
+	for _, alt := range p.alts {
+		if matchSegments(alt, segs, p.ignoreCase, true) {
+			return true
+		}
+	}
+	return false
+}
+
+func split(path string) []string {
+	path = filepath.ToSlash(path)
+	path = strings.TrimPrefix(path, "./")
+	if path == "" || path == "." {
+		return []string{}
+	}
+	return strings.Split(path, "/")
+}
+
+// Compile parses the given `pattern`. If `ignoreCase` is true, the pattern matches regardless of case.
+func Compile(pattern string, ignoreCase bool) (*Pattern, error) {
+	alts, err := expandBraces(pattern)
+	if err != nil {
+		return nil, err
+	}
+	p := &Pattern{
+		raw:        pattern,
+		ignoreCase: ignoreCase,
+		alts:       [][]string{},
+	}
+	for _, alt := range alts {
+		segs := split(alt)
+		for _, seg := range segs {
+			if err := validateSegment(seg); err != nil {
+				return nil, errors.ErrInvalidPattern(pattern, err)
+			}
+		}
+		p.alts = append(p.alts, segs)
+	}
+	return p, nil
+}
+
+// MustCompile works like Compile but panics if the pattern is invalid.
+func MustCompile(pattern string, ignoreCase bool) *Pattern {
+	p, err := Compile(pattern, ignoreCase)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// Match returns true if `path` matches `pattern`. Invalid patterns never match.
+func Match(pattern, path string, ignoreCase bool) bool {
+	p, err := Compile(pattern, ignoreCase)
+	if err != nil {
+		return false
+	}
+	return p.Match(path)
+}
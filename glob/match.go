@@ -0,0 +1,126 @@
+package glob
+
+import (
+	"unicode"
+)
+
+func matchSegments(pat, path []string, ignoreCase, partial bool) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			if partial {
+				return true
+			}
+			rest := pat[1:]
+			for i := 0; i <= len(path); i++ {
+				if matchSegments(rest, path[i:], ignoreCase, false) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(path) == 0 {
+			return partial
+		}
+		if !matchSegment(pat[0], path[0], ignoreCase) {
+			return false
+		}
+		pat, path = pat[1:], path[1:]
+	}
+	return len(path) == 0
+}
+
+func runeEqual(a, b rune, ignoreCase bool) bool {
+	if a == b {
+		return true
+	}
+	return ignoreCase && unicode.ToLower(a) == unicode.ToLower(b)
+}
+
+// matchSegment matches a single path segment using backtracking for `*`.
+func matchSegment(pattern, segment string, ignoreCase bool) bool {
+	p, s := []rune(pattern), []rune(segment)
+	pi, si := 0, 0
+	starP, starS := -1, 0
+	for si < len(s) {
+		if pi < len(p) {
+			switch p[pi] {
+			case '*':
+				starP, starS = pi, si
+				pi++
+				continue
+			case '?':
+				pi++
+				si++
+				continue
+			case '[':
+				if ok, n := matchClass(p[pi:], s[si], ignoreCase); ok {
+					pi += n
+					si++
+					continue
+				}
+			case '\\':
+				if pi+1 < len(p) && runeEqual(p[pi+1], s[si], ignoreCase) {
+					pi += 2
+					si++
+					continue
+				}
+			default:
+				if runeEqual(p[pi], s[si], ignoreCase) {
+					pi++
+					si++
+					continue
+				}
+			}
+		}
+		if starP < 0 {
+			return false
+		}
+		// let the last star consume one more character and retry
+		starS++
+		pi, si = starP+1, starS
+	}
+	for pi < len(p) && p[pi] == '*' {
+		pi++
+	}
+	return pi == len(p)
+}
+
+// matchClass matches `c` against the character class at the start of `class`.
+// It returns whether it matched and the length of the class expression.
+func matchClass(class []rune, c rune, ignoreCase bool) (bool, int) {
+	i := 1
+	negate := false
+	if i < len(class) && (class[i] == '!' || class[i] == '^') {
+		negate = true
+		i++
+	}
+	matched := false
+	first := true
+	for i < len(class) && (first || class[i] != ']') {
+		first = false
+		lo := class[i]
+		if lo == '\\' && i+1 < len(class) {
+			i++
+			lo = class[i]
+		}
+		i++
+		hi := lo
+		if i+1 < len(class) && class[i] == '-' && class[i+1] != ']' {
+			hi = class[i+1]
+			if hi == '\\' && i+2 < len(class) {
+				i++
+				hi = class[i+1]
+			}
+			i += 2
+		}
+		if inRange(lo, hi, c) || (ignoreCase && (inRange(lo, hi, unicode.ToLower(c)) || inRange(lo, hi, unicode.ToUpper(c)))) {
+			matched = true
+		}
+	}
+	if i >= len(class) {
+		return false, 0 // unterminated, rejected by Compile
+	}
+	return matched != negate, i + 1
+}
+
+func inRange(lo, hi, c rune) bool { return lo <= c && c <= hi }
@@ -0,0 +1,107 @@
+package glob
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/toxyl/flo/errors"
+)
+
+// validateSegment checks that all classes are terminated and the segment doesn't end with an escape.
+func validateSegment(seg string) error {
+	r := []rune(seg)
+	for i := 0; i < len(r); i++ {
+		switch r[i] {
+		case '\\':
+			if i+1 >= len(r) {
+				return fmt.Errorf("trailing escape")
+			}
+			i++
+		case '[':
+			_, n := matchClass(r[i:], 0, false)
+			if n == 0 {
+				return fmt.Errorf("unterminated character class")
+			}
+			i += n - 1
+		}
+	}
+	return nil
+}
+
+// expandBraces expands `{a,b}` alternatives, e.g. `x.{yml,yaml}` becomes `x.yml` and `x.yaml`.
+// Braces without a comma are taken literally.
+func expandBraces(pattern string) ([]string, error) {
+	start, end, commas := -1, -1, []int{}
+	depth := 0
+	r := []rune(pattern)
+scan:
+	for i := 0; i < len(r); i++ {
+		switch r[i] {
+		case '\\':
+			i++
+		case '[':
+			// braces inside classes are literal
+			for i++; i < len(r) && r[i] != ']'; i++ {
+			}
+		case '{':
+			if depth == 0 {
+				start = i
+				commas = commas[:0]
+			}
+			depth++
+		case ',':
+			if depth == 1 {
+				commas = append(commas, i)
+			}
+		case '}':
+			if depth == 0 {
+				continue
+			}
+			depth--
+			if depth == 0 {
+				if len(commas) > 0 {
+					end = i
+					break scan
+				}
+				start = -1
+			}
+		}
+	}
+	if depth > 0 {
+		return nil, errors.ErrInvalidPattern(pattern, fmt.Errorf("unterminated brace"))
+	}
+	if end < 0 {
+		return []string{pattern}, nil
+	}
+
+	prefix, suffix := string(r[:start]), string(r[end+1:])
+	bounds := append(append([]int{start}, commas...), end)
+	res := []string{}
+	for i := 0; i < len(bounds)-1; i++ {
+		alt := string(r[bounds[i]+1 : bounds[i+1]])
+		expanded, err := expandBraces(prefix + alt + suffix)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, expanded...)
+	}
+	return dedupe(res), nil
+}
+
+func dedupe(list []string) []string {
+	seen := map[string]bool{}
+	res := []string{}
+	for _, s := range list {
+		if !seen[s] {
+			seen[s] = true
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+// hasSeparator reports whether the pattern contains a path separator. Classes aren't special here,
+// because Compile splits patterns into segments at every separator, so a class can't contain one.
+func hasSeparator(pattern string) bool {
+	return strings.Contains(pattern, "/")
+}
@@ -0,0 +1,99 @@
+package glob
+
+import (
+	"testing"
+)
+
+func TestPattern_Match(t *testing.T) {
+	tests := []struct {
+		pattern    string
+		path       string
+		ignoreCase bool
+		want       bool
+	}{
+		{"*.yaml", "config.yaml", false, true},
+		{"*.yaml", "dir/config.yaml", false, false},
+		{"**/*.yaml", "config.yaml", false, true},
+		{"**/*.yaml", "a/b/c/config.yaml", false, true},
+		{"a/**/c", "a/c", false, true},
+		{"a/**/c", "a/b/x/c", false, true},
+		{"a/**", "a/b/c", false, true},
+		{"a/**/c", "a/b/x/d", false, false},
+		{"?.go", "a.go", false, true},
+		{"?.go", "ab.go", false, false},
+		{"[a-c]x", "bx", false, true},
+		{"[!a-c]x", "bx", false, false},
+		{"[^a-c]x", "dx", false, true},
+		{"[]a]", "]", false, true},
+		{"*.{yml,yaml}", "a.yaml", false, true},
+		{"*.{yml,yaml}", "a.yml", false, true},
+		{"*.{yml,yaml}", "a.json", false, false},
+		{"{a,b{c,d}}/x", "bd/x", false, true},
+		{"{a}", "{a}", false, true},
+		{"\\*", "*", false, true},
+		{"\\*", "a", false, false},
+		{"*.YAML", "config.yaml", false, false},
+		{"*.YAML", "config.yaml", true, true},
+		{"[A-C]*", "bar", true, true},
+		{"a*b*c", "axxbyyc", false, true},
+		{"a*b*c", "axxbyy", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.path, func(t *testing.T) {
+			p, err := Compile(tt.pattern, tt.ignoreCase)
+			if err != nil {
+				t.Fatalf("Compile(%q) failed: %v", tt.pattern, err)
+			}
+			if got := p.Match(tt.path); got != tt.want {
+				t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPattern_MatchPrefix(t *testing.T) {
+	p := MustCompile("src/*/test/*.go", false)
+	for path, want := range map[string]bool{
+		"src":          true,
+		"src/a":        true,
+		"src/a/test":   true,
+		"src/a/other":  false,
+		"docs":         false,
+		"src/a/test/x": false,
+	} {
+		if got := p.MatchPrefix(path); got != want {
+			t.Errorf("MatchPrefix(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestCompile_Invalid(t *testing.T) {
+	for _, pattern := range []string{"[a-z", "a\\", "{a,b"} {
+		if _, err := Compile(pattern, false); err == nil {
+			t.Errorf("Compile(%q) should have failed", pattern)
+		}
+	}
+}
+
+func TestFilter_Match(t *testing.T) {
+	f, err := NewFilter([]string{"*.yaml", "docs/**"}, []string{"vendor", "*.tmp.yaml"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]bool{
+		"a.yaml":           true,
+		"x/y/a.yaml":       true,
+		"docs/readme.md":   true,
+		"main.go":          false,
+		"vendor":           false,
+		"x/vendor":         false,
+		"x/cache.tmp.yaml": false,
+		"vendor/a.yaml":    false,
+		"x/vendor/y.yaml":  false,
+		"vendors/a.yaml":   true,
+	} {
+		if got := f.Match(path); got != want {
+			t.Errorf("Match(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
@@ -3,9 +3,16 @@ module github.com/toxyl/flo
 go 1.24.1
 
 require (
+	github.com/cespare/xxhash/v2 v2.3.0
 	github.com/toxyl/errors v0.0.0-20240410073853-96b96b437ed5
 	github.com/toxyl/glog v1.0.0-alpha.18
+	golang.org/x/crypto v0.48.0
 	gopkg.in/yaml.v3 v3.0.1
+	lukechampine.com/blake3 v1.4.1
 )
 
-require github.com/toxyl/math v0.0.1-alpha.4 // indirect
+require (
+	github.com/klauspost/cpuid/v2 v2.0.9 // indirect
+	github.com/toxyl/math v0.0.1-alpha.4 // indirect
+	golang.org/x/sys v0.41.0 // indirect
+)
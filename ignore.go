@@ -0,0 +1,33 @@
+package flo
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/toxyl/flo/ignore"
+)
+
+// IsIgnored returns true if `f` is ignored by the ignore files with the given `names`
+// (defaults to ".gitignore") in this directory or any directory between it and `f`.
+// The rules use gitignore semantics, files outside of this directory are never ignored.
+func (d *DirObj) IsIgnored(f *FileObj, names ...string) bool {
+	if len(names) == 0 {
+		names = []string{".gitignore"}
+	}
+	rel, err := filepath.Rel(d.Path(), f.Path())
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return false
+	}
+	segs := strings.Split(filepath.ToSlash(rel), "/")
+	var rules *ignore.Matcher
+	dir := d.Path()
+	for i := range segs[:len(segs)-1] {
+		rules, _ = rules.Load(dir, strings.Join(segs[:i], "/"), names...)
+		if rules.Match(strings.Join(segs[:i+1], "/"), true) {
+			return true
+		}
+		dir = filepath.Join(dir, segs[i])
+	}
+	rules, _ = rules.Load(dir, strings.Join(segs[:len(segs)-1], "/"), names...)
+	return rules.Match(rel, f.IsDir())
+}
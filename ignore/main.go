@@ -0,0 +1,179 @@
+package ignore
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/toxyl/flo/glob"
+)
+
+type rule struct {
+	raw     string
+	base    string // directory of the ignore file, relative to the root
+	pattern *glob.Pattern
+	negate  bool
+	dirOnly bool
+}
+
+func (r *rule) match(path string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if r.base != "" {
+		if !strings.HasPrefix(path, r.base+"/") {
+			return false
+		}
+		path = strings.TrimPrefix(path, r.base+"/")
+	}
+	return r.pattern.Match(path)
+}
+
+// Matcher decides whether paths are ignored using gitignore semantics.
+// Rules added later take precedence over earlier ones, so rules of ignore files
+// in subdirectories must be added after those of their parents.
+// A nil Matcher ignores nothing.
+type Matcher struct {
+	ignoreCase bool
+	rules      []*rule
+}
+
+// parseLine converts a line of an ignore file into a rule, nil is returned for blank lines and comments.
+func parseLine(line, base string, ignoreCase bool) (*rule, error) {
+	line = strings.TrimSuffix(line, "\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil, nil
+	}
+	// trailing spaces are ignored unless escaped
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = strings.TrimSuffix(line, " ")
+	}
+	if line == "" {
+		return nil, nil
+	}
+	r := &rule{raw: line, base: base}
+	if strings.HasPrefix(line, "!") {
+		r.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, "\\!") || strings.HasPrefix(line, "\\#") {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") && !strings.HasSuffix(line, "\\/") {
+		r.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	// a separator at the start or in the middle anchors the pattern to the directory of the ignore file
+	if !strings.Contains(line, "/") {
+		line = "**/" + line
+	}
+	line = strings.TrimPrefix(line, "/")
+	// gitignore doesn't know braces
+	line = strings.NewReplacer("{", "\\{", "}", "\\}").Replace(line)
+
+	p, err := glob.Compile(line, ignoreCase)
+	if err != nil {
+		return nil, err
+	}
+	r.pattern = p
+	return r, nil
+}
+
+// Add returns a new Matcher with the rules in `lines` added. `base` is the directory
+// the rules are relative to, as path relative to the root (use "" for the root itself).
+func (m *Matcher) Add(base string, lines ...string) (*Matcher, error) {
+	res := &Matcher{}
+	if m != nil {
+		res.ignoreCase = m.ignoreCase
+		res.rules = append(res.rules, m.rules...)
+	}
+	base = strings.Trim(filepath.ToSlash(base), "/")
+	if base == "." {
+		base = ""
+	}
+	for _, line := range lines {
+		r, err := parseLine(line, base, res.ignoreCase)
+		if err != nil {
+			return nil, err
+		}
+		if r != nil {
+			res.rules = append(res.rules, r)
+		}
+	}
+	return res, nil
+}
+
+// AddFile works like Add but reads the rules from the file at `path`.
+func (m *Matcher) AddFile(base, path string) (*Matcher, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	lines := []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return m.Add(base, lines...)
+}
+
+// Load adds the rules of all ignore files with the given `names` found in `dir`.
+// `base` is the path of `dir` relative to the root. Missing files are skipped,
+// if none of the files exist, `m` is returned.
+func (m *Matcher) Load(dir, base string, names ...string) (*Matcher, error) {
+	res := m
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		next, err := res.AddFile(base, path)
+		if err != nil {
+			return m, err
+		}
+		res = next
+	}
+	return res, nil
+}
+
+// Match returns true if `path` (relative to the root) is ignored by the rules.
+// Parent directories are not considered, see Ignored.
+func (m *Matcher) Match(path string, isDir bool) bool {
+	if m == nil {
+		return false
+	}
+	path = strings.Trim(filepath.ToSlash(path), "/")
+	for i := len(m.rules) - 1; i >= 0; i-- {
+		if m.rules[i].match(path, isDir) {
+			return !m.rules[i].negate
+		}
+	}
+	return false
+}
+
+// Ignored returns true if `path` (relative to the root) or one of its parent directories is ignored.
+// Just like git does, a file can't be re-included if one of its parent directories is ignored.
+func (m *Matcher) Ignored(path string, isDir bool) bool {
+	if m == nil {
+		return false
+	}
+	segs := strings.Split(strings.Trim(filepath.ToSlash(path), "/"), "/")
+	for i := 1; i < len(segs); i++ {
+		if m.Match(strings.Join(segs[:i], "/"), true) {
+			return true
+		}
+	}
+	return m.Match(path, isDir)
+}
+
+// New returns an empty Matcher. If `ignoreCase` is true, rules match regardless of case.
+func New(ignoreCase bool) *Matcher {
+	return &Matcher{
+		ignoreCase: ignoreCase,
+		rules:      []*rule{},
+	}
+}
@@ -0,0 +1,62 @@
+package ignore
+
+import (
+	"testing"
+)
+
+func TestMatcher_Ignored(t *testing.T) {
+	m, err := New(false).Add("",
+		"# comment",
+		"*.log",
+		"!important.log",
+		"/build",
+		"node_modules/",
+		"docs/**/*.tmp",
+		"\\#hash",
+		"{braces}",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err = m.Add("sub", "*.txt", "!keep.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"app.log", false, true},
+		{"a/b/app.log", false, true},
+		{"important.log", false, false},
+		{"build", true, true},
+		{"build/out.bin", false, true},
+		{"a/build", true, false},
+		{"node_modules", true, true},
+		{"a/node_modules/x.js", false, true},
+		{"node_modules", false, false},
+		{"docs/a/b/x.tmp", false, true},
+		{"docs/x.tmp", false, true},
+		{"x.tmp", false, false},
+		{"#hash", false, true},
+		{"{braces}", false, true},
+		{"sub/a.txt", false, true},
+		{"sub/keep.txt", false, false},
+		{"a.txt", false, false},
+		{"sub/keep.log", false, true},
+	}
+	for _, tt := range tests {
+		if got := m.Ignored(tt.path, tt.isDir); got != tt.want {
+			t.Errorf("Ignored(%q, %v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestMatcher_Nil(t *testing.T) {
+	var m *Matcher
+	if m.Ignored("a/b", false) {
+		t.Error("nil matcher must not ignore anything")
+	}
+}
@@ -14,25 +14,86 @@ import (
 	"github.com/toxyl/glog"
 )
 
-func (f *FileObj) updateInfo() {
-	s, err := os.Stat(f.path)
-	f.info.Ownership.Update()
-	f.info.Name = f.Name()
-	f.info.Mode = 0
-	f.info.LastModified = time.Time{}
-	f.info.Exists = !os.IsNotExist(err)
-	f.info.Size = 0
-	f.info.Checksum = checksum.New(config.ChecksumAlgorithm, f.path)
-	f.info.Permissions = permissions.New(f.path)
-	f.info.Path = f.Path()
-
-	if f.info.Exists && s != nil {
-		f.info.LastModified = s.ModTime()
-		f.info.Mode = s.Mode()
-		if f.info.Permissions.HasSize() {
-			f.info.Size = s.Size()
+// load reads the file's metadata. If the file was found while reading a directory,
+// the DirEntry is used so that only links need an additional os.Stat.
+func (f *FileObj) load() {
+	var lstat, stat fs.FileInfo
+	var err error
+	if f.entry != nil {
+		lstat, err = f.entry.Info()
+		f.entry = nil
+	} else {
+		lstat, err = os.Lstat(f.path)
+	}
+	stat = lstat
+	if err == nil && lstat.Mode()&fs.ModeSymlink != 0 {
+		stat, err = os.Stat(f.path)
+	}
+
+	info := &FileInfo{
+		Name:         f.Name(),
+		Exists:       !os.IsNotExist(err),
+		LastModified: time.Time{},
+		Mode:         0,
+		Size:         0,
+		Path:         f.Path(),
+		Checksum:     f.checksumObj(),
+	}
+	if lstat == nil {
+		info.Permissions = permissions.NewFromModes(0, 0)
+	} else if err != nil {
+		info.Permissions = permissions.NewFromModes(lstat.Mode(), 0) // dead link
+	} else {
+		info.Permissions = permissions.NewFromModes(lstat.Mode(), stat.Mode())
+	}
+	if err == nil && stat != nil {
+		info.Ownership = ownership.NewFromFileInfo(f.path, stat)
+		info.LastModified = stat.ModTime()
+		info.Mode = stat.Mode()
+		if info.Permissions.HasSize() {
+			info.Size = stat.Size()
 		}
+	} else {
+		info.Ownership = ownership.NewFromFileInfo(f.path, nil)
+	}
+	f.info = info
+	f.loaded = true
+}
+
+// checksumObj returns the checksum of the previously loaded metadata, so that it survives reloads
+// and Changed() keeps working. A new one is created if the algorithm has been changed.
+func (f *FileObj) checksumObj() *checksum.Checksum {
+	if f.info != nil && f.info.Checksum != nil && f.info.Checksum.Algorithm() == config.ChecksumAlgorithm {
+		return f.info.Checksum.WithCache(config.ChecksumCache)
+	}
+	return checksum.New(config.ChecksumAlgorithm, f.path).WithCache(config.ChecksumCache)
+}
+
+// meta returns the file's metadata, reading it first if necessary.
+func (f *FileObj) meta() *FileInfo {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.loaded {
+		f.load()
 	}
+	return f.info
+}
+
+// invalidate drops the cached metadata, it will be read again when needed.
+func (f *FileObj) invalidate() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.entry = nil
+	f.loaded = false
+}
+
+// Refresh reads the file's metadata again, e.g. after it has been changed by another process.
+func (f *FileObj) Refresh() *FileObj {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.entry = nil
+	f.load()
+	return f
 }
 
 type FileInfo struct {
@@ -0,0 +1,105 @@
+package flo
+
+import (
+	"io"
+	"iter"
+	"os"
+	"path/filepath"
+
+	"github.com/toxyl/flo/errors"
+	"github.com/toxyl/flo/ignore"
+)
+
+// iterBatchSize is the number of directory entries read at once while iterating.
+const iterBatchSize = 256
+
+// entries yields the direct children of the directory in the order the filesystem returns them.
+// `isDir` tells whether an entry is a directory so callers can descend without another stat,
+// if `follow` is true, links to directories count as directories.
+// If the directory can't be read, the directory itself is yielded with the error.
+func (d *DirObj) entries(follow bool, yield func(f *FileObj, isDir bool, err error) bool) bool {
+	dir, err := os.Open(d.Path())
+	if err != nil {
+		return yield(d.FileObj, true, errors.ErrFailedToOpenFile(d.Path(), err))
+	}
+	defer dir.Close()
+	for {
+		batch, err := dir.ReadDir(iterBatchSize)
+		for _, entry := range batch {
+			path := filepath.Join(d.Path(), entry.Name())
+			if !yield(newFileFromEntry(path, entry), entryIsDir(path, entry, follow), nil) {
+				return false
+			}
+		}
+		if err == io.EOF {
+			return true
+		}
+		if err != nil {
+			return yield(d.FileObj, true, errors.ErrFailedToReadFile(d.Path(), err))
+		}
+	}
+}
+
+func (d *DirObj) walkSeq(root string, depth int, opts *WalkOptions, rules *ignore.Matcher, anc *ancestors, yield func(f *FileObj, err error) bool) bool {
+	if opts.MaxDepth >= 0 && depth > opts.MaxDepth {
+		return true
+	}
+	rules = opts.loadIgnore(root, d, rules)
+	anc = anc.push(d.Path())
+	return d.entries(opts.follow(), func(f *FileObj, isDir bool, err error) bool {
+		if err != nil {
+			return yield(f, err)
+		}
+		visit, descend := opts.accept(root, f, isDir, rules)
+		if visit && !yield(f, nil) {
+			return false
+		}
+		if !descend || (opts.MaxDepth >= 0 && depth+1 > opts.MaxDepth) {
+			return true
+		}
+		sub := f.AsDir()
+		if err := anc.check(sub); err != nil {
+			return yield(f, err)
+		}
+		return sub.walkSeq(root, depth+1, opts, rules, anc, yield)
+	})
+}
+
+// Entries returns an iterator over the files and directories directly contained in this directory.
+// Entries are streamed in the order the filesystem returns them, unlike Contents they are not sorted.
+// If the directory can't be read, the directory itself is yielded together with the error.
+func (d *DirObj) Entries() iter.Seq2[*FileObj, error] {
+	return func(yield func(f *FileObj, err error) bool) {
+		d.entries(true, func(f *FileObj, isDir bool, err error) bool { return yield(f, err) })
+	}
+}
+
+// Walk returns an iterator over the entire tree below this directory, descending into each
+// directory right after it has been yielded. Directories that can't be read are yielded together
+// with the error, so are links that would lead into a cycle. Of the `opts` only MaxDepth, Filter,
+// IgnoreFiles and Follow are used, if `opts` is nil the entire tree is walked.
+func (d *DirObj) Walk(opts *WalkOptions) iter.Seq2[*FileObj, error] {
+	if opts == nil {
+		opts = DefaultWalkOptions()
+	}
+	return func(yield func(f *FileObj, err error) bool) {
+		if !opts.skipRoot(d) {
+			d.walkSeq(d.Path(), 0, opts, nil, nil, yield)
+		}
+	}
+}
+
+// All returns an iterator over the entire tree below this directory.
+// Unlike Walk, directories that can't be read are skipped silently, just like Each does.
+func (d *DirObj) All() iter.Seq[*FileObj] {
+	return func(yield func(f *FileObj) bool) {
+		for f, err := range d.Walk(nil) {
+			if err != nil {
+				continue
+			}
+			if !yield(f) {
+				return
+			}
+		}
+	}
+}
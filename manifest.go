@@ -0,0 +1,333 @@
+package flo
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/toxyl/flo/checksum"
+	c "github.com/toxyl/flo/codec"
+	"github.com/toxyl/flo/errors"
+)
+
+// ManifestFormat is the line format of a checksum manifest.
+type ManifestFormat int
+
+const (
+	// ManifestText writes "<sum>  <name>" lines, like sha256sum does.
+	ManifestText ManifestFormat = iota
+	// ManifestBinary writes "<sum> *<name>" lines, like sha256sum --binary does.
+	ManifestBinary
+	// ManifestTagged writes BSD-style "SHA256 (<name>) = <sum>" lines, like sha256sum --tag does.
+	ManifestTagged
+)
+
+type ManifestOptions struct {
+	// Format of the lines written, when verifying all formats are accepted.
+	Format ManifestFormat
+	// Walk selects the files listed in the manifest and those checked for EXTRA entries.
+	// Only regular files (or links to them) are listed. If nil, all files are used.
+	Walk *WalkOptions
+}
+
+func DefaultManifestOptions() *ManifestOptions {
+	return &ManifestOptions{
+		Format: ManifestText,
+		Walk:   nil,
+	}
+}
+
+// ManifestStatus is the result of verifying a single manifest entry.
+type ManifestStatus int
+
+const (
+	ManifestOK      ManifestStatus = iota // checksum matches
+	ManifestFailed                        // checksum doesn't match or the file can't be read
+	ManifestMissing                       // listed but doesn't exist
+	ManifestExtra                         // exists but isn't listed
+)
+
+func (s ManifestStatus) String() string {
+	switch s {
+	case ManifestOK:
+		return "OK"
+	case ManifestFailed:
+		return "FAILED"
+	case ManifestMissing:
+		return "MISSING"
+	case ManifestExtra:
+		return "EXTRA"
+	}
+	return "UNKNOWN"
+}
+
+type ManifestEntry struct {
+	Name      string // as listed in the manifest, slash-separated
+	Status    ManifestStatus
+	Algorithm *c.Codec // nil for EXTRA entries
+	Expected  string
+	Actual    string
+	Err       error // set if the file can't be read
+}
+
+type ManifestReport struct {
+	Entries []*ManifestEntry
+	// Malformed are the line numbers of lines that couldn't be parsed.
+	Malformed []int
+}
+
+// OK returns true if all entries are OK and there were no malformed lines.
+func (r *ManifestReport) OK() bool {
+	return len(r.Malformed) == 0 && r.Count(ManifestOK) == len(r.Entries)
+}
+
+// Count returns the number of entries with the given status.
+func (r *ManifestReport) Count(status ManifestStatus) int {
+	n := 0
+	for _, e := range r.Entries {
+		if e.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// manifestTags maps algorithms to the tags used by GNU coreutils.
+var manifestTags = map[*c.Codec]string{
+	c.MD5:        "MD5",
+	c.SHA1:       "SHA1",
+	c.SHA224:     "SHA224",
+	c.SHA256:     "SHA256",
+	c.SHA384:     "SHA384",
+	c.SHA512:     "SHA512",
+	c.SHA3_256:   "SHA3-256",
+	c.SHA3_512:   "SHA3-512",
+	c.BLAKE2B256: "BLAKE2b-256",
+	c.BLAKE2B512: "BLAKE2b",
+}
+
+// manifestLengths maps hex digest lengths to the algorithm assumed for untagged lines.
+var manifestLengths = map[int]*c.Codec{
+	32:  c.MD5,
+	40:  c.SHA1,
+	56:  c.SHA224,
+	64:  c.SHA256,
+	96:  c.SHA384,
+	128: c.SHA512,
+}
+
+func manifestTag(algo *c.Codec) string {
+	if tag, ok := manifestTags[algo]; ok {
+		return tag
+	}
+	return strings.ToUpper(algo.Name)
+}
+
+func manifestAlgorithm(tag string) *c.Codec {
+	for algo, t := range manifestTags {
+		if t == tag {
+			return algo
+		}
+	}
+	algo, _ := checksum.Get(tag)
+	return algo
+}
+
+// escapeManifestName escapes names the way coreutils does, the line has to be prefixed with a
+// backslash if the name has been escaped.
+func escapeManifestName(name string) (string, bool) {
+	if !strings.ContainsAny(name, "\\\n\r") {
+		return name, false
+	}
+	return strings.NewReplacer("\\", "\\\\", "\n", "\\n", "\r", "\\r").Replace(name), true
+}
+
+func unescapeManifestName(name string) string {
+	return strings.NewReplacer("\\\\", "\\", "\\n", "\n", "\\r", "\r").Replace(name)
+}
+
+// formatManifestLine returns the line (without line break) for `name` in the given format.
+func formatManifestLine(format ManifestFormat, algo *c.Codec, sum, name string) string {
+	name, escaped := escapeManifestName(name)
+	line := ""
+	switch format {
+	case ManifestTagged:
+		line = manifestTag(algo) + " (" + name + ") = " + sum
+	case ManifestBinary:
+		line = sum + " *" + name
+	default:
+		line = sum + "  " + name
+	}
+	if escaped {
+		line = "\\" + line
+	}
+	return line
+}
+
+// parseManifestLine parses a line in any of the formats. If `algo` is nil,
+// the algorithm is taken from the tag or guessed from the length of the digest.
+func parseManifestLine(line string, algo *c.Codec) (a *c.Codec, sum, name string, ok bool) {
+	escaped := strings.HasPrefix(line, "\\")
+	if escaped {
+		line = line[1:]
+	}
+	if i := strings.LastIndex(line, ") = "); i > 0 && strings.Contains(line[:i], " (") {
+		j := strings.Index(line, " (")
+		tag, name, sum := line[:j], line[j+2:i], line[i+4:]
+		if algo == nil {
+			algo = manifestAlgorithm(tag)
+		}
+		if escaped {
+			name = unescapeManifestName(name)
+		}
+		return algo, sum, name, algo != nil && sum != "" && name != ""
+	}
+	i := strings.Index(line, " ")
+	if i <= 0 || i+2 > len(line) || (line[i+1] != ' ' && line[i+1] != '*') {
+		return nil, "", "", false
+	}
+	sum, name = line[:i], line[i+2:]
+	if algo == nil {
+		algo = manifestLengths[len(sum)]
+	}
+	if escaped {
+		name = unescapeManifestName(name)
+	}
+	return algo, sum, name, algo != nil && name != ""
+}
+
+// manifestFiles returns the regular files below the directory by slash-separated relative name,
+// `exclude` (the manifest itself) is skipped.
+func (d *DirObj) manifestFiles(opts *WalkOptions, exclude string) (map[string]*FileObj, error) {
+	res := map[string]*FileObj{}
+	for f, err := range d.Walk(opts) {
+		if err != nil {
+			return nil, err
+		}
+		if f.IsDir() || !f.Exists() || !f.meta().Mode.IsRegular() || f.Path() == exclude {
+			continue
+		}
+		rel, err := filepath.Rel(d.Path(), f.Path())
+		if err != nil {
+			continue
+		}
+		res[filepath.ToSlash(rel)] = f
+	}
+	return res, nil
+}
+
+// WriteManifest writes a manifest with the checksums (calculated with `algo`, e.g. codec.SHA256)
+// of all files below the directory to `path`, sorted by name. The output is byte-compatible with
+// GNU sha256sum, md5sum etc. run from within the directory. If `path` is inside the directory,
+// it is not listed. If `opts` is nil, DefaultManifestOptions() are used.
+func (d *DirObj) WriteManifest(path string, algo *c.Codec, opts *ManifestOptions) error {
+	if opts == nil {
+		opts = DefaultManifestOptions()
+	}
+	if algo == nil || algo.Hash == nil {
+		return errors.ErrChecksumAlgorithmInvalid(checksum.Name(algo))
+	}
+	manifest := File(path)
+	files, err := d.manifestFiles(opts.Walk, manifest.Path())
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	sb := strings.Builder{}
+	for _, name := range names {
+		sum, err := files[name].Digest(algo)
+		if err != nil {
+			return err
+		}
+		sb.WriteString(formatManifestLine(opts.Format, algo, algo.Format(sum), name))
+		sb.WriteByte('\n')
+	}
+	return manifest.StoreString(sb.String())
+}
+
+// VerifyManifest checks the files below the directory against the manifest at `path`.
+// The algorithm is taken from tagged lines or guessed from the digest length (MD5, SHA1, SHA-2)
+// for untagged lines, use VerifyManifestWith for other algorithms.
+func (d *DirObj) VerifyManifest(path string) (*ManifestReport, error) {
+	return d.VerifyManifestWith(path, nil, nil)
+}
+
+// VerifyManifestWith works like VerifyManifest, if `algo` is not nil it is used for all lines.
+// Files selected by `opts.Walk` that are not listed are reported as EXTRA.
+// The error is only set if the manifest can't be read.
+func (d *DirObj) VerifyManifestWith(path string, algo *c.Codec, opts *ManifestOptions) (*ManifestReport, error) {
+	if opts == nil {
+		opts = DefaultManifestOptions()
+	}
+	manifest := File(path)
+	file, err := manifest.TryOpenReadOnly()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	report := &ManifestReport{}
+	listed := map[string]bool{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		a, sum, name, ok := parseManifestLine(line, algo)
+		if !ok || a.Hash == nil {
+			report.Malformed = append(report.Malformed, n)
+			continue
+		}
+		e := &ManifestEntry{Name: name, Algorithm: a, Expected: strings.ToLower(sum)}
+		report.Entries = append(report.Entries, e)
+
+		p := filepath.FromSlash(name)
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(d.Path(), p)
+			listed[filepath.ToSlash(filepath.Clean(filepath.FromSlash(name)))] = true
+		}
+		if _, err := os.Stat(p); os.IsNotExist(err) {
+			e.Status = ManifestMissing
+			continue
+		}
+		digest, err := File(p).Digest(a)
+		if err != nil {
+			e.Status, e.Err = ManifestFailed, err
+			continue
+		}
+		e.Actual = a.Format(digest)
+		if e.Actual == e.Expected {
+			e.Status = ManifestOK
+		} else {
+			e.Status = ManifestFailed
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.ErrFailedToReadFile(manifest.Path(), err)
+	}
+
+	files, err := d.manifestFiles(opts.Walk, manifest.Path())
+	if err != nil {
+		return nil, err
+	}
+	extra := []string{}
+	for name := range files {
+		if !listed[name] {
+			extra = append(extra, name)
+		}
+	}
+	slices.Sort(extra)
+	for _, name := range extra {
+		report.Entries = append(report.Entries, &ManifestEntry{Name: name, Status: ManifestExtra})
+	}
+	return report, nil
+}
@@ -0,0 +1,58 @@
+package flo
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/toxyl/flo/glob"
+)
+
+// Match returns true if the file matches the glob `pattern` (see the glob package for the syntax).
+// Patterns without a path separator are matched against the name of the file,
+// absolute patterns against its path and all others against the end of its path.
+func (f *FileObj) Match(pattern string) bool { return f.MatchWith(pattern, false) }
+
+// MatchWith works like Match, if `ignoreCase` is true the pattern matches regardless of case.
+func (f *FileObj) MatchWith(pattern string, ignoreCase bool) bool {
+	if !strings.Contains(pattern, "/") {
+		return glob.Match(pattern, f.Name(), ignoreCase)
+	}
+	if !filepath.IsAbs(pattern) {
+		pattern = "**/" + pattern
+	}
+	return glob.Match(pattern, f.Path(), ignoreCase)
+}
+
+func (d *DirObj) glob(p *glob.Pattern, rel string, res *[]*FileObj) {
+	d.entries(false, func(f *FileObj, isDir bool, err error) bool {
+		if err != nil {
+			return true // unreadable directories are skipped, just like Each does
+		}
+		r := filepath.Join(rel, f.Name())
+		if p.Match(r) {
+			*res = append(*res, f)
+		}
+		if isDir && p.MatchPrefix(r) {
+			f.AsDir().glob(p, r, res)
+		}
+		return true
+	})
+}
+
+// Glob returns all files and directories below this directory whose relative path matches
+// the glob `pattern` (see the glob package for the syntax), sorted by path.
+// Links to directories are matched but not descended into.
+func (d *DirObj) Glob(pattern string) ([]*FileObj, error) { return d.GlobWith(pattern, false) }
+
+// GlobWith works like Glob, if `ignoreCase` is true the pattern matches regardless of case.
+func (d *DirObj) GlobWith(pattern string, ignoreCase bool) ([]*FileObj, error) {
+	p, err := glob.Compile(pattern, ignoreCase)
+	if err != nil {
+		return nil, err
+	}
+	res := []*FileObj{}
+	d.glob(p, "", &res)
+	sort.Slice(res, func(i, j int) bool { return res[i].Path() < res[j].Path() })
+	return res, nil
+}
@@ -0,0 +1,61 @@
+package ownership
+
+import (
+	"os/user"
+	"sync"
+)
+
+// the user database rarely changes while we are running but lookups can be slow
+// (e.g. when NSS has to ask LDAP), so we remember all names we've seen.
+var (
+	cacheMu sync.RWMutex
+	users   = map[string]string{}
+	groups  = map[string]string{}
+)
+
+func lookupCached(cache map[string]string, id string, fnLookup func(id string) (string, error)) (string, bool) {
+	cacheMu.RLock()
+	name, ok := cache[id]
+	cacheMu.RUnlock()
+	if ok {
+		return name, name != ""
+	}
+	name, err := fnLookup(id)
+	if err != nil {
+		name = "" // also cache failures, so we don't retry unknown IDs over and over again
+	}
+	cacheMu.Lock()
+	cache[id] = name
+	cacheMu.Unlock()
+	return name, name != ""
+}
+
+// LookupUser returns the name of the user with the given `uid`.
+func LookupUser(uid string) (name string, ok bool) {
+	return lookupCached(users, uid, func(id string) (string, error) {
+		u, err := user.LookupId(id)
+		if err != nil {
+			return "", err
+		}
+		return u.Username, nil
+	})
+}
+
+// LookupGroup returns the name of the group with the given `gid`.
+func LookupGroup(gid string) (name string, ok bool) {
+	return lookupCached(groups, gid, func(id string) (string, error) {
+		g, err := user.LookupGroupId(id)
+		if err != nil {
+			return "", err
+		}
+		return g.Name, nil
+	})
+}
+
+// ClearCache forgets all user and group names looked up so far.
+func ClearCache() {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	clear(users)
+	clear(groups)
+}
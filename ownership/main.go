@@ -1,26 +1,63 @@
 package ownership
 
+import (
+	"io/fs"
+	"sync"
+)
+
 type FileOwnership struct {
-	file  string
-	user  string
-	uid   string
-	group string
-	gid   string
+	mu       sync.Mutex
+	file     string
+	user     string
+	uid      string
+	group    string
+	gid      string
+	resolved bool
 }
 
-func (fo *FileOwnership) User() string  { return fo.user }
+func (fo *FileOwnership) User() string  { fo.resolve(); return fo.user }
 func (fo *FileOwnership) UID() string   { return fo.uid }
-func (fo *FileOwnership) Group() string { return fo.group }
+func (fo *FileOwnership) Group() string { fo.resolve(); return fo.group }
 func (fo *FileOwnership) GID() string   { return fo.gid }
 
-func New(filepath string) *FileOwnership {
-	fo := &FileOwnership{
-		file:  filepath,
-		user:  "?",
-		uid:   "?",
-		group: "?",
-		gid:   "?",
+// resolve looks up the user and group names, this only happens once
+// and only when a name is actually requested.
+func (fo *FileOwnership) resolve() {
+	fo.mu.Lock()
+	defer fo.mu.Unlock()
+	if fo.resolved {
+		return
+	}
+	fo.resolved = true
+	if name, ok := LookupUser(fo.uid); ok {
+		fo.user = name
+	}
+	if name, ok := LookupGroup(fo.gid); ok {
+		fo.group = name
+	}
+}
+
+func newOwnership(filepath string) *FileOwnership {
+	return &FileOwnership{
+		file:     filepath,
+		user:     "?",
+		uid:      "?",
+		group:    "?",
+		gid:      "?",
+		resolved: false,
 	}
+}
+
+func New(filepath string) *FileOwnership {
+	fo := newOwnership(filepath)
 	fo.Update()
 	return fo
 }
+
+// NewFromFileInfo works like New but takes the IDs from `info` (as returned by os.Stat)
+// instead of reading them from disk again. If `info` is nil, the ownership is unknown.
+func NewFromFileInfo(filepath string, info fs.FileInfo) *FileOwnership {
+	fo := newOwnership(filepath)
+	fo.updateFromFileInfo(info)
+	return fo
+}
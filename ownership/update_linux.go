@@ -4,20 +4,30 @@ package ownership
 
 import (
 	"fmt"
+	"io/fs"
 	"os"
-	"os/user"
 	"syscall"
 )
 
 func (fo *FileOwnership) Update() {
 	if stat, err := os.Stat(fo.file); err == nil {
-		if u, err := user.LookupId(fmt.Sprint(stat.Sys().(*syscall.Stat_t).Uid)); err == nil {
-			fo.user = u.Username
-			fo.uid = u.Uid
-		}
-		if g, err := user.LookupGroupId(fmt.Sprint(stat.Sys().(*syscall.Stat_t).Gid)); err == nil {
-			fo.group = g.Name
-			fo.gid = g.Gid
-		}
+		fo.updateFromFileInfo(stat)
 	}
 }
+
+func (fo *FileOwnership) updateFromFileInfo(info fs.FileInfo) {
+	if info == nil {
+		return
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return
+	}
+	fo.mu.Lock()
+	defer fo.mu.Unlock()
+	fo.uid = fmt.Sprint(stat.Uid)
+	fo.gid = fmt.Sprint(stat.Gid)
+	fo.user = "?"
+	fo.group = "?"
+	fo.resolved = false
+}
@@ -3,6 +3,7 @@
 package ownership
 
 import (
+	"io/fs"
 	"os/user"
 )
 
@@ -10,8 +11,13 @@ func (fo *FileOwnership) Update() {
 	// windows doesn't have the same user concept as linux,
 	// let's be lazy and just assume the current user to be the owner
 	u, _ := user.Current()
+	fo.mu.Lock()
+	defer fo.mu.Unlock()
 	fo.user = u.Name
 	fo.uid = u.Uid
 	fo.group = fo.uid
 	fo.gid = u.Gid
+	fo.resolved = true
 }
+
+func (fo *FileOwnership) updateFromFileInfo(info fs.FileInfo) { fo.Update() }
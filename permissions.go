@@ -6,20 +6,46 @@ import (
 	"os/user"
 	"strconv"
 
+	"github.com/toxyl/flo/errors"
 	"github.com/toxyl/flo/log"
+	"github.com/toxyl/flo/permissions"
 )
 
-func (f *FileObj) Own(username string) error {
-	defer f.updateInfo()
+// lookupUser returns the uid and primary gid of `username`, which can also be a numeric uid.
+func lookupUser(username string) (uid, gid int, err error) {
 	u, err := user.Lookup(username)
 	if err != nil {
-		return err
+		if _, errID := strconv.Atoi(username); errID != nil {
+			return 0, 0, err
+		}
+		if u, err = user.LookupId(username); err != nil {
+			return 0, 0, err
+		}
+	}
+	if uid, err = strconv.Atoi(u.Uid); err != nil {
+		return 0, 0, err
+	}
+	if gid, err = strconv.Atoi(u.Gid); err != nil {
+		return 0, 0, err
+	}
+	return uid, gid, nil
+}
+
+// lookupGroup returns the gid of `group`, which can also be a numeric gid.
+func lookupGroup(group string) (gid int, err error) {
+	if gid, err := strconv.Atoi(group); err == nil {
+		return gid, nil
 	}
-	uid, err := strconv.Atoi(u.Uid)
+	g, err := user.LookupGroup(group)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	gid, err := strconv.Atoi(u.Gid)
+	return strconv.Atoi(g.Gid)
+}
+
+func (f *FileObj) Own(username string) error {
+	defer f.invalidate()
+	uid, gid, err := lookupUser(username)
 	if err != nil {
 		return err
 	}
@@ -27,49 +53,67 @@ func (f *FileObj) Own(username string) error {
 }
 
 func (f *FileObj) Perm(mode fs.FileMode) error {
-	defer f.updateInfo()
+	defer f.invalidate()
 	return os.Chmod(f.path, mode)
 }
 
+// Chmod changes the permissions using a chmod expression (e.g. "u=rwx,g+rX,o-w" or "2755"),
+// see permissions.Parse. Clauses without u, g, o or a respect the umask of the process.
+func (f *FileObj) Chmod(expr string) error {
+	m, err := permissions.Parse(expr)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return errors.ErrFile("stat", f.path, err)
+	}
+	mode := m.Apply(info.Mode(), permissions.Umask())
+	if err := f.Perm(mode); err != nil {
+		return errors.ErrFailedToSetPermissions(f.path, mode, err)
+	}
+	return nil
+}
+
 func (f *FileObj) PermOwner(r, w, x bool) *FileObj {
-	f.info.Permissions.Owner().Set(r, w, x)
-	log.Error(f.Perm(f.info.Permissions.FileMode()), "Setting owner permissions on %s failed!", f.Path()) // aka +x
+	f.meta().Permissions.Owner().Set(r, w, x)
+	log.Error(f.Perm(f.meta().Permissions.FileMode()), "Setting owner permissions on %s failed!", f.Path()) // aka +x
 	return f
 }
 
 func (f *FileObj) PermGroup(r, w, x bool) *FileObj {
-	f.info.Permissions.Group().Set(r, w, x)
-	log.Error(f.Perm(f.info.Permissions.FileMode()), "Setting group permissions on %s failed!", f.Path()) // aka +x
+	f.meta().Permissions.Group().Set(r, w, x)
+	log.Error(f.Perm(f.meta().Permissions.FileMode()), "Setting group permissions on %s failed!", f.Path()) // aka +x
 	return f
 }
 
 func (f *FileObj) PermWorld(r, w, x bool) *FileObj {
-	f.info.Permissions.World().Set(r, w, x)
-	log.Error(f.Perm(f.info.Permissions.FileMode()), "Setting world permissions on %s failed!", f.Path()) // aka +x
+	f.meta().Permissions.World().Set(r, w, x)
+	log.Error(f.Perm(f.meta().Permissions.FileMode()), "Setting world permissions on %s failed!", f.Path()) // aka +x
 	return f
 }
 
 func (f *FileObj) PermExec(owner, group, world bool) *FileObj {
 	// implicitly we will also set +r permissions as they are required for +x to work
 	if owner {
-		f.info.Permissions.Owner().SetExec()
-		f.info.Permissions.Owner().SetRead()
+		f.meta().Permissions.Owner().SetExec()
+		f.meta().Permissions.Owner().SetRead()
 	} else {
-		f.info.Permissions.Owner().ClearExec()
+		f.meta().Permissions.Owner().ClearExec()
 	}
 	if group {
-		f.info.Permissions.Group().SetExec()
-		f.info.Permissions.Group().SetRead()
+		f.meta().Permissions.Group().SetExec()
+		f.meta().Permissions.Group().SetRead()
 	} else {
-		f.info.Permissions.Group().ClearExec()
+		f.meta().Permissions.Group().ClearExec()
 	}
 	if world {
-		f.info.Permissions.World().SetExec()
-		f.info.Permissions.World().SetRead()
+		f.meta().Permissions.World().SetExec()
+		f.meta().Permissions.World().SetRead()
 	} else {
-		f.info.Permissions.World().ClearExec()
+		f.meta().Permissions.World().ClearExec()
 	}
-	log.Error(f.Perm(f.info.Permissions.FileMode()), "Making %s executable failed!", f.Path()) // aka +x
+	log.Error(f.Perm(f.meta().Permissions.FileMode()), "Making %s executable failed!", f.Path()) // aka +x
 	return f
 }
 
@@ -80,24 +124,24 @@ func (f *FileObj) PermExecAll() *FileObj {
 func (f *FileObj) PermRead(owner, group, world bool) *FileObj {
 	// implicitly we will also clear +x permissions as they don't work with +r
 	if owner {
-		f.info.Permissions.Owner().SetRead()
+		f.meta().Permissions.Owner().SetRead()
 	} else {
-		f.info.Permissions.Owner().ClearRead()
-		f.info.Permissions.Owner().ClearExec()
+		f.meta().Permissions.Owner().ClearRead()
+		f.meta().Permissions.Owner().ClearExec()
 	}
 	if group {
-		f.info.Permissions.Group().SetRead()
+		f.meta().Permissions.Group().SetRead()
 	} else {
-		f.info.Permissions.Group().ClearRead()
-		f.info.Permissions.Group().ClearExec()
+		f.meta().Permissions.Group().ClearRead()
+		f.meta().Permissions.Group().ClearExec()
 	}
 	if world {
-		f.info.Permissions.World().SetRead()
+		f.meta().Permissions.World().SetRead()
 	} else {
-		f.info.Permissions.World().ClearRead()
-		f.info.Permissions.World().ClearExec()
+		f.meta().Permissions.World().ClearRead()
+		f.meta().Permissions.World().ClearExec()
 	}
-	log.Error(f.Perm(f.info.Permissions.FileMode()), "Making %s readable failed!", f.Path())
+	log.Error(f.Perm(f.meta().Permissions.FileMode()), "Making %s readable failed!", f.Path())
 	return f
 }
 
@@ -107,21 +151,21 @@ func (f *FileObj) PermReadAll() *FileObj {
 
 func (f *FileObj) PermWrite(owner, group, world bool) *FileObj {
 	if owner {
-		f.info.Permissions.Owner().SetWrite()
+		f.meta().Permissions.Owner().SetWrite()
 	} else {
-		f.info.Permissions.Owner().ClearWrite()
+		f.meta().Permissions.Owner().ClearWrite()
 	}
 	if group {
-		f.info.Permissions.Group().SetWrite()
+		f.meta().Permissions.Group().SetWrite()
 	} else {
-		f.info.Permissions.Group().ClearWrite()
+		f.meta().Permissions.Group().ClearWrite()
 	}
 	if world {
-		f.info.Permissions.World().SetWrite()
+		f.meta().Permissions.World().SetWrite()
 	} else {
-		f.info.Permissions.World().ClearWrite()
+		f.meta().Permissions.World().ClearWrite()
 	}
-	log.Error(f.Perm(f.info.Permissions.FileMode()), "Making %s writable failed!", f.Path()) // aka +x
+	log.Error(f.Perm(f.meta().Permissions.FileMode()), "Making %s writable failed!", f.Path()) // aka +x
 	return f
 }
 
@@ -132,20 +176,20 @@ func (f *FileObj) PermWriteAll() *FileObj {
 func (f *FileObj) IsExecutable() bool {
 	return f.IsExecutableWorld() || f.IsExecutableGroup() || f.IsExecutableOwner()
 }
-func (f *FileObj) IsExecutableWorld() bool { return f.info.Permissions.World().HasExec() }
-func (f *FileObj) IsExecutableGroup() bool { return f.info.Permissions.Group().HasExec() }
-func (f *FileObj) IsExecutableOwner() bool { return f.info.Permissions.Owner().HasExec() }
+func (f *FileObj) IsExecutableWorld() bool { return f.meta().Permissions.World().HasExec() }
+func (f *FileObj) IsExecutableGroup() bool { return f.meta().Permissions.Group().HasExec() }
+func (f *FileObj) IsExecutableOwner() bool { return f.meta().Permissions.Owner().HasExec() }
 
 func (f *FileObj) IsReadable() bool {
 	return f.IsReadableWorld() || f.IsReadableGroup() || f.IsReadableOwner()
 }
-func (f *FileObj) IsReadableWorld() bool { return f.info.Permissions.World().HasRead() }
-func (f *FileObj) IsReadableGroup() bool { return f.info.Permissions.Group().HasRead() }
-func (f *FileObj) IsReadableOwner() bool { return f.info.Permissions.Owner().HasRead() }
+func (f *FileObj) IsReadableWorld() bool { return f.meta().Permissions.World().HasRead() }
+func (f *FileObj) IsReadableGroup() bool { return f.meta().Permissions.Group().HasRead() }
+func (f *FileObj) IsReadableOwner() bool { return f.meta().Permissions.Owner().HasRead() }
 
 func (f *FileObj) IsWritable() bool {
 	return f.IsWritableWorld() || f.IsWritableGroup() || f.IsWritableOwner()
 }
-func (f *FileObj) IsWritableWorld() bool { return f.info.Permissions.World().HasWrite() }
-func (f *FileObj) IsWritableGroup() bool { return f.info.Permissions.Group().HasWrite() }
-func (f *FileObj) IsWritableOwner() bool { return f.info.Permissions.Owner().HasWrite() }
+func (f *FileObj) IsWritableWorld() bool { return f.meta().Permissions.World().HasWrite() }
+func (f *FileObj) IsWritableGroup() bool { return f.meta().Permissions.Group().HasWrite() }
+func (f *FileObj) IsWritableOwner() bool { return f.meta().Permissions.Owner().HasWrite() }
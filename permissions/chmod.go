@@ -0,0 +1,394 @@
+package permissions
+
+import (
+	"io/fs"
+	"strconv"
+	"strings"
+
+	"github.com/toxyl/flo/errors"
+)
+
+const (
+	bitsUser  fs.FileMode = 04700
+	bitsGroup fs.FileMode = 02070
+	bitsOther fs.FileMode = 01007
+	bitsAll   fs.FileMode = 07777
+)
+
+// Unix converts the mode to classic unix permission bits, including setuid, setgid and sticky.
+func Unix(mode fs.FileMode) fs.FileMode {
+	perm := mode & fs.ModePerm
+	if mode&fs.ModeSetuid != 0 {
+		perm |= 04000
+	}
+	if mode&fs.ModeSetgid != 0 {
+		perm |= 02000
+	}
+	if mode&fs.ModeSticky != 0 {
+		perm |= 01000
+	}
+	return perm
+}
+
+// FromUnix converts classic unix permission bits to a mode.
+func FromUnix(perm fs.FileMode) fs.FileMode {
+	mode := perm & fs.ModePerm
+	if perm&04000 != 0 {
+		mode |= fs.ModeSetuid
+	}
+	if perm&02000 != 0 {
+		mode |= fs.ModeSetgid
+	}
+	if perm&01000 != 0 {
+		mode |= fs.ModeSticky
+	}
+	return mode
+}
+
+// action is a single operation of a chmod expression, e.g. the `+x` of `u+x`.
+type action struct {
+	op       byte
+	who      fs.FileMode // affected bits, 0 if no who was given
+	perm     fs.FileMode // bits to set or clear
+	condExec bool        // X, execute if a directory or someone can already execute
+	copy     fs.FileMode // bits to copy from (one of the bitsX), e.g. the `u` of `g=u`
+	keep     fs.FileMode // bits `=` doesn't touch on directories
+}
+
+// Mode is a parsed chmod expression, see Parse.
+type Mode struct {
+	expr    string
+	actions []action
+}
+
+func (m *Mode) String() string { return m.expr }
+
+// Parse parses `expr` using the syntax of chmod: either an octal mode (e.g. "2755") or a
+// comma-separated list of symbolic clauses (e.g. "u=rwx,g+rX,o-w"). A clause consists of any
+// combination of u, g, o and a, followed by one or more actions. Each action is one of +, - and =,
+// followed by either any combination of r, w, x, X, s and t, one of u, g and o (to copy those
+// permissions) or an octal mode. Just like chmod does, clauses without u, g, o or a respect the umask.
+func Parse(expr string) (*Mode, error) {
+	m := &Mode{expr: expr, actions: []action{}}
+	if isOctal(expr) {
+		a, err := parseOctal('=', expr, true)
+		if err != nil {
+			return nil, errors.ErrInvalidPermissions(expr)
+		}
+		m.actions = append(m.actions, a)
+		return m, nil
+	}
+	for _, clause := range strings.Split(expr, ",") {
+		i, who := 0, fs.FileMode(0)
+		for ; i < len(clause) && strings.IndexByte("ugoa", clause[i]) >= 0; i++ {
+			who |= whoBits(clause[i])
+		}
+		if i >= len(clause) {
+			return nil, errors.ErrInvalidPermissions(expr)
+		}
+		for i < len(clause) {
+			op := clause[i]
+			if strings.IndexByte("+-=", op) < 0 {
+				return nil, errors.ErrInvalidPermissions(expr)
+			}
+			j := i + 1
+			for j < len(clause) && strings.IndexByte("+-=", clause[j]) < 0 {
+				j++
+			}
+			a, err := parseAction(op, who, clause[i+1:j])
+			if err != nil {
+				return nil, errors.ErrInvalidPermissions(expr)
+			}
+			m.actions = append(m.actions, a)
+			i = j
+		}
+	}
+	return m, nil
+}
+
+// MustParse works like Parse but panics if `expr` is invalid.
+func MustParse(expr string) *Mode {
+	m, err := Parse(expr)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+func isOctal(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '7' {
+			return false
+		}
+	}
+	return true
+}
+
+func whoBits(c byte) fs.FileMode {
+	switch c {
+	case 'u':
+		return bitsUser
+	case 'g':
+		return bitsGroup
+	case 'o':
+		return bitsOther
+	}
+	return bitsAll
+}
+
+// parseOctal parses an octal action. Like chmod does, an absolute octal mode with less than
+// five digits doesn't clear the setuid and setgid bits of directories, unless it sets them.
+// If `absolute` is false, the mode follows an operator and all bits are changed.
+func parseOctal(op byte, digits string, absolute bool) (action, error) {
+	v, err := strconv.ParseUint(digits, 8, 32)
+	if err != nil || v > uint64(bitsAll) {
+		return action{}, errors.ErrInvalidPermissions(digits)
+	}
+	a := action{op: op, who: bitsAll, perm: fs.FileMode(v)}
+	if absolute && len(digits) < 5 {
+		a.keep = 06000 &^ a.perm
+	}
+	return a, nil
+}
+
+func parseAction(op byte, who fs.FileMode, perms string) (action, error) {
+	if isOctal(perms) {
+		if who != 0 {
+			return action{}, errors.ErrInvalidPermissions(perms)
+		}
+		return parseOctal(op, perms, false)
+	}
+	a := action{op: op, who: who}
+	if len(perms) == 1 && strings.IndexByte("ugo", perms[0]) >= 0 {
+		a.copy = whoBits(perms[0])
+		if op == '=' {
+			a.keep = 06000
+		}
+		return a, nil
+	}
+	for _, r := range perms {
+		switch r {
+		case 'r':
+			a.perm |= 0444
+		case 'w':
+			a.perm |= 0222
+		case 'x':
+			a.perm |= 0111
+		case 'X':
+			a.condExec = true
+		case 's':
+			a.perm |= 06000
+		case 't':
+			a.perm |= 01000
+		default:
+			return action{}, errors.ErrInvalidPermissions(perms)
+		}
+	}
+	if op == '=' {
+		// like chmod does, setuid and setgid of directories are only cleared if mentioned
+		a.keep = 06000 &^ a.perm
+	}
+	return a, nil
+}
+
+// copyBits spreads the rwx bits of `perm` selected by `from` to all classes.
+func copyBits(perm, from fs.FileMode) fs.FileMode {
+	var rwx fs.FileMode
+	switch from {
+	case bitsUser:
+		rwx = perm >> 6 & 07
+	case bitsGroup:
+		rwx = perm >> 3 & 07
+	case bitsOther:
+		rwx = perm & 07
+	}
+	return rwx<<6 | rwx<<3 | rwx
+}
+
+// bits returns the bits affected by the action and their value for the unix permission bits `perm`,
+// affected is 0 if no who was given.
+func (a action) bits(perm fs.FileMode, isDir bool) (affected, value fs.FileMode) {
+	affected, value = a.who, a.perm
+	if a.copy != 0 {
+		value = copyBits(perm, a.copy)
+	}
+	if a.condExec && (isDir || perm&0111 != 0) {
+		value |= 0111
+	}
+	return affected, value
+}
+
+// apply applies the action to the unix permission bits `perm`.
+func (a action) apply(perm fs.FileMode, isDir bool, umask fs.FileMode) fs.FileMode {
+	affected, value := a.bits(perm, isDir)
+	if affected == 0 {
+		// without who, all bits are affected but the umask is respected
+		affected, value = bitsAll, value&^umask
+	}
+	value &= affected
+	switch a.op {
+	case '+':
+		return perm | value
+	case '-':
+		return perm &^ value
+	}
+	keep := fs.FileMode(0)
+	if isDir {
+		keep = a.keep
+	}
+	return perm&^(affected&^keep) | value
+}
+
+// Apply returns `mode` with the expression applied. `mode` is a regular fs.FileMode,
+// its type bits decide whether X applies and are preserved. `umask` is used for clauses
+// without u, g, o or a, see Umask.
+func (m *Mode) Apply(mode fs.FileMode, umask fs.FileMode) fs.FileMode {
+	perm := Unix(mode)
+	isDir := mode.IsDir()
+	for _, a := range m.actions {
+		perm = a.apply(perm, isDir, umask&fs.ModePerm)
+	}
+	return mode&^(fs.ModePerm|fs.ModeSetuid|fs.ModeSetgid|fs.ModeSticky) | FromUnix(perm)
+}
+
+// Matches returns true if the permissions of `mode` satisfy the expression: "+" requires the bits
+// to be set, "-" requires them to be cleared and "=" requires exactly those bits within the affected
+// classes, e.g. "o+w" matches world-writable files. An octal mode has to match exactly.
+// Unlike Apply, clauses without u, g, o or a affect all classes.
+func (m *Mode) Matches(mode fs.FileMode) bool {
+	perm := Unix(mode)
+	isDir := mode.IsDir()
+	for _, a := range m.actions {
+		affected, value := a.bits(perm, isDir)
+		if affected == 0 {
+			affected = bitsAll
+		}
+		value &= affected
+		switch a.op {
+		case '+':
+			if perm&value != value {
+				return false
+			}
+		case '-':
+			if perm&value != 0 {
+				return false
+			}
+		default:
+			if perm&affected != value {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// classes are the permission classes in the order they are rendered.
+var classes = []struct {
+	who     byte
+	bits    fs.FileMode
+	special fs.FileMode // the special bit of the class and its letter
+	letter  byte
+}{
+	{'u', bitsUser, 04000, 's'},
+	{'g', bitsGroup, 02000, 's'},
+	{'o', bitsOther, 01000, 't'},
+}
+
+// letters returns the permissions of the class `i` in `perm` as letters, e.g. "rwx".
+func letters(perm fs.FileMode, i int) string {
+	c := classes[i]
+	shift := 3 * (2 - i)
+	sb := strings.Builder{}
+	for j, l := range "rwx" {
+		if perm>>shift&(04>>j) != 0 {
+			sb.WriteRune(l)
+		}
+	}
+	if perm&c.special != 0 {
+		sb.WriteByte(c.letter)
+	}
+	return sb.String()
+}
+
+// grouped renders a clause for each class with a non-empty action, as returned by `fn`
+// (e.g. "=rx" or "+s-w"), merging classes with the same action.
+func grouped(fn func(i int) string) []string {
+	res := []string{}
+	done := make([]bool, len(classes))
+	for i := range classes {
+		if done[i] || fn(i) == "" {
+			continue
+		}
+		who := []byte{}
+		for j := i; j < len(classes); j++ {
+			if !done[j] && fn(j) == fn(i) {
+				who = append(who, classes[j].who)
+				done[j] = true
+			}
+		}
+		if len(who) == len(classes) {
+			who = []byte{'a'}
+		}
+		res = append(res, string(who)+fn(i))
+	}
+	return res
+}
+
+// diff returns the letters in `a` that are missing in `b`.
+func diff(a, b string) string {
+	res := []rune{}
+	for _, r := range a {
+		if !strings.ContainsRune(b, r) {
+			res = append(res, r)
+		}
+	}
+	return string(res)
+}
+
+// Symbolic renders the permissions of `mode` as the shortest symbolic chmod expression that sets them
+// absolutely, e.g. 0755 becomes "a=rx,u+w". Since chmod keeps setuid and setgid of directories unless
+// they are mentioned, those are cleared explicitly if `mode` is a directory.
+func Symbolic(mode fs.FileMode) string {
+	perm := Unix(mode)
+	dir := mode.IsDir()
+	// keeps returns the special bit of the class `i` if `=` would keep it
+	keeps := func(i int) fs.FileMode {
+		if dir && classes[i].special&06000 != 0 {
+			return classes[i].special
+		}
+		return 0
+	}
+	best := strings.Join(grouped(func(i int) string {
+		res := "=" + letters(perm, i)
+		if keeps(i)&^perm != 0 {
+			res += "-" + string(classes[i].letter)
+		}
+		return res
+	}), ",")
+	// try a base for all classes and adjust the classes that differ from it,
+	// `a=` can only set setuid and setgid together
+	for b := fs.FileMode(0); b <= 07; b++ {
+		for _, special := range []fs.FileMode{0, 01000, 06000, 07000} {
+			base := b<<6 | b<<3 | b | special
+			cand := []string{"a=" + letters(base, 0) + diff(letters(base, 2), letters(base, 0))}
+			cand = append(cand, grouped(func(i int) string {
+				res := ""
+				// bits `a=` might have kept have to be added and removed explicitly
+				if add := diff(letters(perm, i), letters(base, i)); add != "" {
+					res += "+" + add
+				}
+				if remove := diff(letters(base|keeps(i), i), letters(perm, i)); remove != "" {
+					res += "-" + remove
+				}
+				return res
+			})...)
+			if s := strings.Join(cand, ","); len(s) < len(best) {
+				best = s
+			}
+		}
+	}
+	return best
+}
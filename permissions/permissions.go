@@ -114,6 +114,9 @@ func (p *Permissions) Octal() string {
 	return fmt.Sprintf("%04o", pn.Uint())
 }
 
+// Symbolic returns the shortest symbolic chmod expression that sets these permissions, see Symbolic.
+func (p *Permissions) Symbolic() string { return Symbolic(p.FileMode()) }
+
 func (p *Permissions) IsDir() bool         { return p.dir }
 func (p *Permissions) IsLink() bool        { return p.mode.link }
 func (p *Permissions) IsBlockDevice() bool { return p.blockDevice }
@@ -180,8 +183,8 @@ func (p *Permissions) Set(perm uint32) *Permissions {
 	return p
 }
 
-func New(path string) *Permissions {
-	p := &Permissions{
+func newPermissions() *Permissions {
+	return &Permissions{
 		raw:        0,
 		rawType:    bitmask.New(0),
 		rawMode:    bitmask.New(0),
@@ -211,6 +214,10 @@ func New(path string) *Permissions {
 		group:       NewPermission(0),
 		world:       NewPermission(0),
 	}
+}
+
+func New(path string) *Permissions {
+	p := newPermissions()
 	path = filepath.Clean(path)
 	p.Set(uint32(utils.GetFileModeL(path)))
 
@@ -221,3 +228,18 @@ func New(path string) *Permissions {
 	}
 	return p
 }
+
+// NewFromModes works like New but uses modes that have already been retrieved,
+// `lmode` as returned by os.Lstat and `mode` as returned by os.Stat.
+// `mode` is only used if `lmode` is a link.
+func NewFromModes(lmode, mode fs.FileMode) *Permissions {
+	p := newPermissions()
+	p.Set(uint32(lmode))
+
+	// we might have a link, for those we'd like the permissions of the target instead
+	if p.IsLink() {
+		p.Set(uint32(mode))
+		p.mode.link = true
+	}
+	return p
+}
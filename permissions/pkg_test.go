@@ -77,3 +77,111 @@ func TestPermissions_From_File_String(t *testing.T) {
 		t.Logf("\n\n")
 	})
 }
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		expr  string
+		mode  fs.FileMode
+		umask fs.FileMode
+		want  fs.FileMode
+	}{
+		{"u=rwx,g+rX,o-w", 0604, 022, 0754},
+		{"u=rwx,g+rX,o-w", fs.ModeDir | 0606, 022, fs.ModeDir | 0754},
+		{"2755", 0644, 022, fs.ModeSetgid | 0755},
+		{"755", fs.ModeDir | fs.ModeSetgid | 0700, 022, fs.ModeDir | fs.ModeSetgid | 0755},
+		{"00755", fs.ModeDir | fs.ModeSetgid | 0700, 022, fs.ModeDir | 0755},
+		{"+x", 0644, 022, 0755},
+		{"+w", 0444, 022, 0644},
+		{"=rX", 0700, 077, 0500},
+		{"g=u", 0750, 022, 0770},
+		{"a+st", 0755, 022, fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky | 0755},
+		{"u+s,g-x,o=", 0777, 022, fs.ModeSetuid | 0760},
+		{"+020", 0600, 022, 0620},
+		{"u+rw-x", 0700, 022, 0600},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			m, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := m.Apply(tt.mode, tt.umask); got != tt.want {
+				t.Errorf("Apply(%v) = %v, want %v", tt.mode, got, tt.want)
+			}
+		})
+	}
+	for _, expr := range []string{"", "u", "x+r", "u+q", "u=rw,", "88", "u+7", "ug"} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q) should fail", expr)
+		}
+	}
+}
+
+func TestMode_Matches(t *testing.T) {
+	for _, tt := range []struct {
+		expr string
+		mode fs.FileMode
+		want bool
+	}{
+		{"0644", 0644, true},
+		{"644", 0664, false},
+		{"4755", fs.ModeSetuid | 0755, true},
+		{"755", fs.ModeSetuid | 0755, false},
+		{"o+w", 0646, true},
+		{"o+w", 0664, false},
+		{"+x", 0755, true},
+		{"+x", 0754, false},
+		{"g-x", 0745, true},
+		{"g-x", 0755, false},
+		{"u=rw", 0644, true},
+		{"u=rw", 0744, false},
+		{"u=rw", fs.ModeSetuid | 0644, false},
+		{"u=rw,go=r", 0644, true},
+		{"a=r", 0444, true},
+		{"a+s", fs.ModeSetuid | fs.ModeSetgid | 0755, true},
+		{"u+s", fs.ModeSetgid | 0755, false},
+		{"+t", fs.ModeDir | fs.ModeSticky | 0777, true},
+		{"u+rw-x", 0644, true},
+		{"u+rw-x", 0744, false},
+		{"g=u", 0770, true},
+		{"g=u", 0750, false},
+	} {
+		m, err := Parse(tt.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := m.Matches(tt.mode); got != tt.want {
+			t.Errorf("%q.Matches(%v) = %v, want %v", tt.expr, tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestSymbolic(t *testing.T) {
+	for _, tt := range []struct {
+		mode fs.FileMode
+		want string
+	}{
+		{0755, "a=rx,u+w"},
+		{0640, "u=rw,g=r,o="},
+		{0777, "a=rwx"},
+		{fs.ModeSticky | 0777, "a=rwxt"},
+		{fs.ModeDir | 0700, "a=,u+rwx-s,g-s"},
+	} {
+		if got := Symbolic(tt.mode); got != tt.want {
+			t.Errorf("Symbolic(%v) = %s, want %s", tt.mode, got, tt.want)
+		}
+	}
+	// rendered expressions must set the permissions regardless of the previous ones
+	for perm := fs.FileMode(0); perm <= 07777; perm++ {
+		for _, dir := range []fs.FileMode{0, fs.ModeDir} {
+			mode := FromUnix(perm) | dir
+			m := MustParse(Symbolic(mode))
+			if got := m.Apply(dir, 0777); got != mode {
+				t.Fatalf("%s applied to %v = %v, want %v", m, dir, got, mode)
+			}
+			if got := m.Apply(FromUnix(07777)|dir, 0777); got != mode {
+				t.Fatalf("%s applied to %v = %v, want %v", m, FromUnix(07777)|dir, got, mode)
+			}
+		}
+	}
+}
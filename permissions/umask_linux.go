@@ -0,0 +1,31 @@
+//go:build linux
+
+package permissions
+
+import (
+	"bufio"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Umask returns the file mode creation mask of the current process.
+func Umask() fs.FileMode {
+	// reading it from /proc doesn't require changing it temporarily
+	if file, err := os.Open("/proc/self/status"); err == nil {
+		defer file.Close()
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			if v, ok := strings.CutPrefix(scanner.Text(), "Umask:"); ok {
+				if mask, err := strconv.ParseUint(strings.TrimSpace(v), 8, 32); err == nil {
+					return fs.FileMode(mask)
+				}
+			}
+		}
+	}
+	mask := syscall.Umask(0)
+	syscall.Umask(mask)
+	return fs.FileMode(mask)
+}
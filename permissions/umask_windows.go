@@ -0,0 +1,8 @@
+//go:build windows
+
+package permissions
+
+import "io/fs"
+
+// Umask always returns 0, there is no file mode creation mask on windows.
+func Umask() fs.FileMode { return 0 }
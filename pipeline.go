@@ -0,0 +1,248 @@
+package flo
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/toxyl/flo/errors"
+)
+
+// Command is a stage of a pipeline, use FileObj.Cmd or FileObj.CmdWith to create it.
+type Command struct {
+	file   *FileObj
+	opts   *ExecOptions
+	stdin  *FileObj
+	stdout *FileObj
+	append bool
+}
+
+// Cmd returns a Command that runs the file with `args`.
+func (f *FileObj) Cmd(args ...any) *Command { return f.CmdWith(NewExecOptions(args...)) }
+
+// CmdWith returns a Command that runs the file using `opts`, if `opts` is nil, NewExecOptions() are used.
+func (f *FileObj) CmdWith(opts *ExecOptions) *Command {
+	if opts == nil {
+		opts = NewExecOptions()
+	}
+	return &Command{file: f, opts: opts}
+}
+
+// From makes the command read its input from `file`, like `< file` in a shell.
+func (c *Command) From(file *FileObj) *Command { c.stdin = file; return c }
+
+// To makes the command write its output to `file`, like `> file` in a shell.
+func (c *Command) To(file *FileObj) *Command { c.stdout, c.append = file, false; return c }
+
+// AppendTo makes the command append its output to `file`, like `>> file` in a shell.
+func (c *Command) AppendTo(file *FileObj) *Command { c.stdout, c.append = file, true; return c }
+
+func (c *Command) String() string {
+	s := strings.Join(append([]string{c.file.Path()}, c.opts.Args...), " ")
+	if c.stdin != nil {
+		s += " < " + c.stdin.Path()
+	}
+	if c.stdout != nil && c.append {
+		s += " >> " + c.stdout.Path()
+	} else if c.stdout != nil {
+		s += " > " + c.stdout.Path()
+	}
+	return s
+}
+
+// PipelineObj connects the output of each command to the input of the next one, like a shell pipe does.
+type PipelineObj struct {
+	cmds     []*Command
+	pipefail bool
+}
+
+// Pipeline returns a pipeline of the given `cmds`.
+func Pipeline(cmds ...*Command) *PipelineObj { return &PipelineObj{cmds: cmds} }
+
+// Pipefail makes the exit code of the pipeline the one of the last command that failed,
+// instead of the one of the last command, like `set -o pipefail` in a shell.
+func (p *PipelineObj) Pipefail() *PipelineObj { p.pipefail = true; return p }
+
+func (p *PipelineObj) String() string {
+	strs := []string{}
+	for _, c := range p.cmds {
+		strs = append(strs, c.String())
+	}
+	return strings.Join(strs, " | ")
+}
+
+// PipelineResult describes a finished pipeline.
+type PipelineResult struct {
+	Stages   []*ExecResult // the result of each command
+	ExitCode int
+	Failed   int    // index of the command that determined the exit code if it failed, otherwise -1
+	Stdout   []byte // the captured output of the last command, unless redirected
+	Duration time.Duration
+}
+
+func (r *PipelineResult) Success() bool { return r.ExitCode == 0 }
+
+// String returns a report with the status of each stage.
+func (r *PipelineResult) String() string {
+	sb := strings.Builder{}
+	for i, s := range r.Stages {
+		fmt.Fprintf(&sb, "%d: %s\n", i, s)
+	}
+	return sb.String()
+}
+
+// pipelineStage is a command of a running pipeline.
+type pipelineStage struct {
+	run    *execRun
+	stderr *bytes.Buffer
+	// stdout is closed once the command is done, if its output is copied by os/exec instead of
+	// being written directly by the child
+	stdout *os.File
+}
+
+// stageOptions returns the options of the `i`th command with stdin and stdout connected.
+// Files opened for the stage are appended to `files` so they can be closed once all stages are started,
+// unless they have to stay open until the stage is done.
+func (p *PipelineObj) stageOptions(i int, stdin *os.File, files *[]*os.File) (opts *ExecOptions, next *os.File, stage *pipelineStage, err error) {
+	stage = &pipelineStage{}
+	c := p.cmds[i]
+	o := *c.opts
+	opts = &o
+	if stdin != nil {
+		opts.Stdin = stdin
+	}
+	if c.stdin != nil {
+		opts.StdinFile = c.stdin
+	}
+	var stdout *os.File
+	switch {
+	case c.stdout != nil:
+		flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		if c.append {
+			flag = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+		}
+		if stdout, err = c.stdout.OpenFile(flag, 0644); err != nil {
+			return nil, nil, nil, err
+		}
+	case i < len(p.cmds)-1:
+		r, w, err := os.Pipe()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		*files = append(*files, r)
+		stdout, next = w, r
+	}
+	if stdout != nil {
+		// stdout is no longer captured, so we capture stderr ourselves
+		if opts.Capture {
+			stage.stderr = &bytes.Buffer{}
+			opts.Stderr = opts.output(opts.Stderr, stage.stderr)
+		}
+		opts.Stdout, opts.Capture = stdout, false
+		if c.opts.Stdout != nil {
+			// os/exec copies the output in the background, so stdout must stay open until the stage is done
+			opts.Stdout = io.MultiWriter(stdout, c.opts.Stdout)
+			stage.stdout = stdout
+		} else {
+			*files = append(*files, stdout)
+		}
+	}
+	return opts, next, stage, nil
+}
+
+// wait waits for the command to finish and returns its result.
+func (s *pipelineStage) wait() *ExecResult {
+	r, _ := s.run.wait()
+	if s.stdout != nil {
+		s.stdout.Close()
+	}
+	if s.stderr != nil {
+		r.Stderr = s.stderr.Bytes()
+	}
+	return r
+}
+
+// Run starts all commands of the pipeline and waits for them to finish. If `ctx` is cancelled,
+// all commands are stopped, see ExecWith. The result is returned whenever the pipeline has been started,
+// the error is set if a command couldn't be started or the exit code of the pipeline isn't 0.
+func (p *PipelineObj) Run(ctx context.Context) (*PipelineResult, error) {
+	res := &PipelineResult{
+		Stages:   make([]*ExecResult, len(p.cmds)),
+		ExitCode: 0,
+		Failed:   -1,
+	}
+	if len(p.cmds) == 0 {
+		return res, nil
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	start := time.Now()
+	stages := make([]*pipelineStage, len(p.cmds))
+	files := []*os.File{}
+	closeFiles := func() {
+		for _, f := range files {
+			f.Close()
+		}
+		files = nil
+	}
+	var stdin *os.File
+	for i, c := range p.cmds {
+		opts, next, stage, err := p.stageOptions(i, stdin, &files)
+		if err == nil {
+			if stage.run, err = c.file.startCmd(ctx, opts); err == nil {
+				stages[i] = stage
+			} else if stage.stdout != nil {
+				stage.stdout.Close()
+			}
+		}
+		if err != nil {
+			// stop the stages already started and reap them
+			cancel()
+			closeFiles()
+			for _, s := range stages[:i] {
+				s.wait()
+			}
+			return nil, err
+		}
+		stdin = next
+	}
+	// the children have their own copies, ours would prevent them from seeing EOF
+	closeFiles()
+
+	for i, s := range stages {
+		res.Stages[i] = s.wait()
+	}
+	res.Duration = time.Since(start)
+	last := res.Stages[len(res.Stages)-1]
+	res.Stdout = last.Stdout
+	res.ExitCode = last.ExitCode
+	if !last.Success() {
+		res.Failed = len(res.Stages) - 1
+	}
+	if p.pipefail {
+		for i := len(res.Stages) - 1; i >= 0; i-- {
+			if !res.Stages[i].Success() {
+				res.ExitCode, res.Failed = res.Stages[i].ExitCode, i
+				break
+			}
+		}
+	}
+	if res.Failed >= 0 {
+		return res, errors.ErrPipelineFailed(p.String(), errors.ErrExecResult(res.Stages[res.Failed].String()))
+	}
+	return res, nil
+}
+
+// Output works like Run but only returns the output of the last command.
+func (p *PipelineObj) Output(ctx context.Context) ([]byte, error) {
+	res, err := p.Run(ctx)
+	if res == nil {
+		return nil, err
+	}
+	return res.Stdout, err
+}
@@ -0,0 +1,1254 @@
+package flo
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"encoding/binary"
+	"encoding/json"
+	"hash"
+	"io"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/toxyl/flo/codec"
+	"github.com/toxyl/flo/glob"
+	"github.com/toxyl/flo/ownership"
+	"github.com/toxyl/glog"
+)
+
+// mkTree creates the files (and their parent directories) below `root`, paths ending with / are directories.
+func mkTree(t *testing.T, root string, paths ...string) {
+	t.Helper()
+	for _, p := range paths {
+		path := filepath.Join(root, p)
+		if p[len(p)-1] == '/' {
+			if err := os.MkdirAll(path, 0755); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(p), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestWalkParallel(t *testing.T) {
+	root := t.TempDir()
+	mkTree(t, root, "a/1", "a/2", "a/b/3", "c/4", "c/d/e/5", "6", "empty/")
+	const want = 12 // 6 files and 6 directories
+	for _, workers := range []int{1, runtime.NumCPU() * 2} {
+		for _, ordered := range []bool{false, true} {
+			for range 200 {
+				opts := DefaultWalkOptions()
+				opts.Workers, opts.Ordered = workers, ordered
+				n := atomic.Int64{}
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				err := Dir(root).WalkParallel(ctx, opts, func(f *FileObj) error { n.Add(1); return nil })
+				cancel()
+				if err != nil {
+					t.Fatalf("workers %d, ordered %v: %v", workers, ordered, err)
+				}
+				if n.Load() != want {
+					t.Fatalf("workers %d, ordered %v: visited %d entries, want %d", workers, ordered, n.Load(), want)
+				}
+			}
+		}
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	sh, cat, sort := File("/bin/sh"), File("/bin/cat"), File("/usr/bin/sort")
+	in := File(filepath.Join(dir, "in.txt"))
+	out := File(filepath.Join(dir, "out.txt"))
+	if err := in.StoreString("b\na\nc\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("pipe", func(t *testing.T) {
+		res, err := Pipeline(sh.Cmd("-c", "echo b; echo a"), sort.Cmd(), cat.Cmd()).Run(ctx)
+		if err != nil || string(res.Stdout) != "a\nb\n" || len(res.Stages) != 3 {
+			t.Fatalf("got %q, %v", res.Stdout, err)
+		}
+	})
+	t.Run("redirects", func(t *testing.T) {
+		if _, err := Pipeline(sort.Cmd().From(in), cat.Cmd().To(out)).Run(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Pipeline(cat.Cmd().From(in).AppendTo(out)).Run(ctx); err != nil {
+			t.Fatal(err)
+		}
+		b, err := os.ReadFile(out.Path())
+		if err != nil || string(b) != "a\nb\nc\nb\na\nc\n" {
+			t.Fatalf("got %q, %v", b, err)
+		}
+		// a redirected stage doesn't feed the next one
+		res, err := Pipeline(cat.Cmd().From(in).To(out), cat.Cmd()).Run(ctx)
+		if err != nil || len(res.Stdout) != 0 {
+			t.Fatalf("got %q, %v", res.Stdout, err)
+		}
+	})
+	t.Run("pipefail", func(t *testing.T) {
+		failing := func() *PipelineObj {
+			return Pipeline(sh.Cmd("-c", "echo x; exit 3"), cat.Cmd(), sh.Cmd("-c", "cat; exit 0"))
+		}
+		res, err := failing().Run(ctx)
+		if err != nil || res.ExitCode != 0 || res.Failed != -1 || res.Stages[0].ExitCode != 3 {
+			t.Fatalf("without pipefail: %v, %v", res, err)
+		}
+		res, err = failing().Pipefail().Run(ctx)
+		if err == nil || res.ExitCode != 3 || res.Failed != 0 || string(res.Stdout) != "x\n" {
+			t.Fatalf("with pipefail: %v, %v", res, err)
+		}
+	})
+	t.Run("tee", func(t *testing.T) {
+		tee, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		opts := NewExecOptions("-c", "echo hello; echo oops >&2").WithStdout(tee).WithStderr(stderr)
+		res, err := Pipeline(sh.CmdWith(opts), cat.Cmd()).Run(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(res.Stdout) != "hello\n" || tee.String() != "hello\n" {
+			t.Fatalf("got %q, tee %q", res.Stdout, tee.String())
+		}
+		if stderr.String() != "oops\n" || string(res.Stages[0].Stderr) != "oops\n" {
+			t.Fatalf("got stderr %q and %q", stderr.String(), res.Stages[0].Stderr)
+		}
+	})
+	t.Run("not executable", func(t *testing.T) {
+		if _, err := Pipeline(sh.Cmd("-c", "sleep 10"), File(filepath.Join(dir, "nope")).Cmd()).Run(ctx); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
+
+func TestSandbox(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("sandboxes are only supported on linux")
+	}
+	sh := File("/bin/sh")
+	run := func(t *testing.T, sb *Sandbox, script string) string {
+		t.Helper()
+		res, err := sh.ExecWith(context.Background(), NewExecOptions("-c", script).WithSandbox(sb))
+		if err != nil {
+			t.Fatalf("%v: %s", err, res)
+		}
+		return string(res.Stdout)
+	}
+	if _, err := sh.ExecWith(context.Background(), NewExecOptions("-c", "true").WithSandbox(NewSandbox().Isolate())); err != nil {
+		t.Skipf("user namespaces are not available: %v", err)
+	}
+
+	t.Run("credentials", func(t *testing.T) {
+		nobody, err := user.Lookup("nobody")
+		if err != nil {
+			t.Skip(err)
+		}
+		if got := run(t, &Sandbox{NewUser: true}, "id -u; id -g"); got != "0\n0\n" {
+			t.Errorf("expected root inside the namespace, got %q", got)
+		}
+		if got := run(t, NewSandbox().Isolate().AsUser("nobody"), "id -u; id -g"); got != nobody.Uid+"\n"+nobody.Gid+"\n" {
+			t.Errorf("expected %s:%s, got %q", nobody.Uid, nobody.Gid, got)
+		}
+		if got := run(t, NewSandbox().Isolate(), "echo $$"); got != "1\n" {
+			t.Errorf("expected pid 1 inside the PID namespace, got %q", got)
+		}
+	})
+	t.Run("limits", func(t *testing.T) {
+		sb := NewSandbox().LimitFiles(64).LimitCPU(2500 * time.Millisecond).LimitMemory(512 << 20)
+		if got := run(t, sb, "ulimit -n; ulimit -Hn; ulimit -t; ulimit -v"); got != "64\n64\n3\n524288\n" {
+			t.Errorf("unexpected limits %q", got)
+		}
+		if got := run(t, NewSandbox().Isolate().LimitFiles(64), "ulimit -n"); got != "64\n" {
+			t.Errorf("unexpected limit inside namespaces %q", got)
+		}
+	})
+	t.Run("no new privileges", func(t *testing.T) {
+		const script = "grep NoNewPrivs /proc/self/status"
+		if got := run(t, NewSandbox().WithNoNewPrivileges().LimitFiles(64), script); got != "NoNewPrivs:\t1\n" {
+			t.Errorf("expected the flag to be set, got %q", got)
+		}
+		if got := run(t, NewSandbox(), script); got != "NoNewPrivs:\t0\n" {
+			t.Errorf("expected the flag to be unset without NoNewPrivileges, got %q", got)
+		}
+		// the flag must not leak into other threads of our process
+		for range 20 {
+			if got := run(t, NewSandbox().LimitFiles(64), script); got != "NoNewPrivs:\t0\n" {
+				t.Fatalf("the flag leaked into another process: %q", got)
+			}
+		}
+	})
+}
+
+func TestQueryType(t *testing.T) {
+	root := t.TempDir()
+	mkTree(t, root, "dir/", "file")
+	for link, target := range map[string]string{"dirlink": "dir", "filelink": "file", "dangling": "nope"} {
+		if err := os.Symlink(target, filepath.Join(root, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	names := func(q *Query) []string {
+		t.Helper()
+		res, err := q.List()
+		if err != nil {
+			t.Fatal(err)
+		}
+		names := []string{}
+		for _, f := range res {
+			names = append(names, f.Name())
+		}
+		return names
+	}
+	d := Dir(root)
+	for typ, want := range map[FileType][]string{
+		TypeDir:          {"dir"},
+		TypeFile:         {"file"},
+		TypeSymlink:      {"dangling", "dirlink", "filelink"},
+		TypeDanglingLink: {"dangling"},
+	} {
+		if got := names(d.Find().Type(typ)); !slices.Equal(got, want) {
+			t.Errorf("type %d: expected %v, got %v", typ, want, got)
+		}
+	}
+	if got := names(d.Find().MaxDepth(0)); len(got) != 0 {
+		t.Errorf("expected no matches with MaxDepth(0), got %v", got)
+	}
+	if got := names(d.Find().MinDepth(0).MaxDepth(1)); len(got) != 5 {
+		t.Errorf("expected the entries without the directory itself, got %v", got)
+	}
+}
+
+func TestTreeChecksum(t *testing.T) {
+	a, b := t.TempDir(), t.TempDir()
+	mkTree(t, a, "x/1", "2")
+	mkTree(t, b, "x/1", "2")
+	for _, dir := range []string{a, b} {
+		if err := os.Symlink(filepath.Join(dir, "nope"), filepath.Join(dir, "dangling")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, links := range []bool{true, false} {
+		opts := DefaultTreeOptions()
+		opts.Links = links
+		ta, err := Dir(a).TreeChecksum(codec.SHA256, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tb, err := Dir(b).TreeChecksum(codec.SHA256, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the dangling links point to different targets
+		if diff := ta.Diff(tb); !slices.Equal(diff, []string{"dangling"}) {
+			t.Errorf("links %v: expected the dangling link to differ, got %v", links, diff)
+		}
+		if diff := ta.Diff(ta.Child("x")); !slices.Equal(diff, []string{"1", "2", "dangling", "x"}) {
+			t.Errorf("unexpected diff against a subtree %v", diff)
+		}
+		if diff := ta.Diff(nil); !slices.Equal(diff, []string{"."}) {
+			t.Errorf("expected the root to differ from nil, got %v", diff)
+		}
+	}
+
+	// links to directories are never followed, so they have to be hashed by their target
+	dir := t.TempDir()
+	mkTree(t, dir, "x/1", "y/1")
+	opts := DefaultTreeOptions()
+	opts.Links = false
+	trees := []*TreeNode{}
+	for _, target := range []string{"x", "y"} {
+		os.Remove(filepath.Join(dir, "l"))
+		if err := os.Symlink(target, filepath.Join(dir, "l")); err != nil {
+			t.Fatal(err)
+		}
+		tree, err := Dir(dir).TreeChecksum(codec.SHA256, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		trees = append(trees, tree)
+	}
+	if diff := trees[0].Diff(trees[1]); !slices.Equal(diff, []string{"l"}) {
+		t.Errorf("expected links to different directories to differ, got %v", diff)
+	}
+}
+
+func TestCopyTree(t *testing.T) {
+	src, dst := t.TempDir(), filepath.Join(t.TempDir(), "copy")
+	mkTree(t, src, "dir/1", "file")
+	for link, target := range map[string]string{"dirlink": "dir", "filelink": "file", "dir/dangling": "../nope"} {
+		if err := os.Symlink(target, filepath.Join(src, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := Dir(src).CopyTree(dst, nil); err != nil {
+		t.Fatal(err)
+	}
+	for link, want := range map[string]string{"dirlink": "dir", "filelink": "file", "dir/dangling": "../nope"} {
+		if got, err := os.Readlink(filepath.Join(dst, link)); err != nil || got != want {
+			t.Errorf("expected %s to link to %s, got %q, %v", link, want, got, err)
+		}
+	}
+	if b, err := os.ReadFile(filepath.Join(dst, "dir/1")); err != nil || string(b) != "dir/1" {
+		t.Errorf("unexpected content %q, %v", b, err)
+	}
+}
+
+//go:embed testdata/embedded
+var embedded embed.FS
+
+func TestInitWithEmbeddedFS(t *testing.T) {
+	filter, err := glob.NewFilter([]string{"*.txt"}, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := Dir(filepath.Join(t.TempDir(), "out"))
+	if err := d.InitWithEmbeddedFS(embedded, "testdata/embedded", 0755, 0644, filter); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := os.ReadFile(filepath.Join(d.Path(), "a/x.txt")); err != nil || string(b) != "x\n" {
+		t.Fatalf("unexpected content %q, %v", b, err)
+	}
+	if _, err := os.Stat(filepath.Join(d.Path(), "b")); !os.IsNotExist(err) {
+		t.Errorf("expected no directory without included files, got %v", err)
+	}
+
+	if err := d.UpdateFromEmbeddedFS(embedded, "testdata/embedded", 0755, 0644, false); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := os.ReadFile(filepath.Join(d.Path(), "b/c/y.yaml")); err != nil || string(b) != "y\n" {
+		t.Fatalf("unexpected content %q, %v", b, err)
+	}
+}
+
+// lengthHash only hashes the number of bytes written, so all files of the same size collide.
+type lengthHash struct{ n uint64 }
+
+func (h *lengthHash) Write(p []byte) (int, error) { h.n += uint64(len(p)); return len(p), nil }
+func (h *lengthHash) Sum(b []byte) []byte         { return binary.BigEndian.AppendUint64(b, h.n) }
+func (h *lengthHash) Reset()                      { h.n = 0 }
+func (h *lengthHash) Size() int                   { return 8 }
+func (h *lengthHash) BlockSize() int              { return 1 }
+
+func TestDedupe(t *testing.T) {
+	root := t.TempDir()
+	for name, content := range map[string]string{"a": "hello", "b": "hello", "c": "hello", "d": "world", "e": "hello!"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dupes, err := Dir(root).FindDuplicates(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dupes.Groups) != 1 || len(dupes.Groups[0].Files) != 3 || dupes.Wasted() != 10 {
+		t.Fatalf("expected a, b and c to be duplicates, got %+v", dupes.Groups)
+	}
+
+	// c is modified after it has been found, the reloaded metadata must not hide that
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(root, "c"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	c := dupes.Groups[0].Files[2]
+	c.Refresh()
+	if ops, err := dupes.Dedupe(DedupeHardlink, true); err != nil || len(ops) != 2 {
+		t.Fatalf("dry run: %v, %v", ops, err)
+	}
+	ops, err := dupes.Dedupe(DedupeHardlink, false)
+	if err == nil || len(ops) != 2 || ops[0].Err != nil || ops[1].Err == nil || ops[1].File != c {
+		t.Fatalf("expected only c to fail, got %v, %v", ops, err)
+	}
+	a, _ := os.Stat(filepath.Join(root, "a"))
+	b, _ := os.Stat(filepath.Join(root, "b"))
+	if !os.SameFile(a, b) {
+		t.Errorf("expected b to be a hardlink to a")
+	}
+	if c, _ := os.Stat(filepath.Join(root, "c")); os.SameFile(a, c) {
+		t.Errorf("expected c to be left alone")
+	}
+
+	// the contents are verified using the algorithm the groups were found with
+	lengthAlgo := codec.NewHashCodec("length", func() hash.Hash { return &lengthHash{} }, codec.FormatHex)
+	opts := DefaultDuplicateOptions()
+	opts.Algorithm = lengthAlgo
+	dir := t.TempDir()
+	mkTree(t, dir, "x", "y")
+	if dupes, err = Dir(dir).FindDuplicates(opts); err != nil || len(dupes.Groups) != 1 {
+		t.Fatalf("expected x and y to collide, got %v, %v", dupes, err)
+	}
+	if ops, err := dupes.Dedupe(DedupeSymlink, false); err != nil {
+		t.Fatalf("%v, %v", ops, err)
+	}
+	if target, err := os.Readlink(filepath.Join(dir, "y")); err != nil || target != "x" {
+		t.Errorf("expected y to link to x, got %q, %v", target, err)
+	}
+}
+
+func TestExecStop(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("requires signals")
+	}
+	sh := File("/bin/sh")
+	const ignoreTerm = `trap "" TERM; sleep 10`
+	run := func(ctx context.Context, opts *ExecOptions) (*ExecResult, time.Duration) {
+		t.Helper()
+		start := time.Now()
+		res, err := sh.ExecWith(ctx, opts)
+		if err == nil || res == nil {
+			t.Fatalf("expected the process to be stopped, got %v, %v", res, err)
+		}
+		return res, time.Since(start)
+	}
+
+	t.Run("timeout", func(t *testing.T) {
+		res, d := run(context.Background(), NewExecOptions("-c", "exec sleep 10").WithTimeout(100*time.Millisecond))
+		if !res.TimedOut || res.Signal != syscall.SIGTERM || d > 2*time.Second {
+			t.Errorf("expected a timeout, got %s (%v)", res, res.Signal)
+		}
+	})
+	t.Run("cancel", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		res, d := run(ctx, NewExecOptions("-c", "exec sleep 10"))
+		if res.TimedOut || res.Signal != syscall.SIGTERM || d > 2*time.Second {
+			t.Errorf("expected the process to be terminated, got %s (%v)", res, res.Signal)
+		}
+	})
+	t.Run("grace", func(t *testing.T) {
+		res, d := run(context.Background(), NewExecOptions("-c", ignoreTerm).WithTimeout(100*time.Millisecond).WithGrace(300*time.Millisecond))
+		if res.Signal != syscall.SIGKILL || d < 400*time.Millisecond || d > 2*time.Second {
+			t.Errorf("expected the process to be killed after the grace period, got %s (%v)", res, res.Signal)
+		}
+	})
+	t.Run("no grace", func(t *testing.T) {
+		res, d := run(context.Background(), NewExecOptions("-c", ignoreTerm).WithTimeout(100*time.Millisecond).WithGrace(0))
+		if res.Signal != syscall.SIGKILL || d > 2*time.Second {
+			t.Errorf("expected the process to be killed right away, got %s (%v)", res, res.Signal)
+		}
+	})
+	t.Run("process group", func(t *testing.T) {
+		// the child ignores SIGTERM and outlives the shell, which exits when asked to
+		script := `(trap "" TERM; sleep 10) >/dev/null 2>&1 & echo $!; trap "exit 0" TERM; while :; do sleep 0.05; done`
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		opts := NewExecOptions("-c", script).WithProcessGroup().WithGrace(300 * time.Millisecond)
+		res, err := sh.ExecWith(ctx, opts)
+		if res == nil || res.ExitCode != 0 {
+			t.Fatalf("expected the shell to exit when asked to, got %v, %v", res, err)
+		}
+		pid := strings.TrimSpace(string(res.Stdout))
+		// the child is either gone or a zombie nobody reaps
+		for deadline := time.Now().Add(2 * time.Second); ; time.Sleep(20 * time.Millisecond) {
+			stat, err := os.ReadFile("/proc/" + pid + "/stat")
+			if err != nil || strings.Contains(string(stat), ") Z ") {
+				break
+			}
+			if time.Now().After(deadline) {
+				if p, err := os.FindProcess(atoiOr(pid, 0)); err == nil {
+					p.Kill()
+				}
+				t.Fatalf("expected the process group to be killed, %s is still running", pid)
+			}
+		}
+	})
+}
+
+func TestProcessStop(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("requires signals")
+	}
+	sh := File("/bin/sh")
+	start := func(script string, opts *ExecOptions) *Process {
+		t.Helper()
+		p, err := sh.Start(context.Background(), opts.WithArgs("-c", script))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	t.Run("terminate", func(t *testing.T) {
+		p := start("exec sleep 10", NewExecOptions())
+		res, _ := p.Stop(5 * time.Second)
+		if res.Signal != syscall.SIGTERM || p.Running() {
+			t.Errorf("expected the process to be terminated, got %s", res)
+		}
+	})
+	t.Run("kill", func(t *testing.T) {
+		p := start(`trap "" TERM; exec sleep 10`, NewExecOptions())
+		time.Sleep(100 * time.Millisecond) // let the shell set up the trap
+		begin := time.Now()
+		res, _ := p.Stop(200 * time.Millisecond)
+		if d := time.Since(begin); res.Signal != syscall.SIGKILL || d < 200*time.Millisecond || d > 2*time.Second {
+			t.Errorf("expected the process to be killed after the grace period, got %s after %s", res, d)
+		}
+	})
+	t.Run("exited", func(t *testing.T) {
+		p := start("exit 3", NewExecOptions())
+		if res, _ := p.Wait(); res.ExitCode != 3 {
+			t.Fatalf("unexpected result %s", res)
+		}
+		if err := p.Signal(syscall.SIGTERM); err != os.ErrProcessDone {
+			t.Errorf("expected ErrProcessDone, got %v", err)
+		}
+		if res, _ := p.Stop(0); res.ExitCode != 3 || res.Signal != nil {
+			t.Errorf("expected the result of the exited process, got %s", res)
+		}
+	})
+	t.Run("restarts", func(t *testing.T) {
+		p := start("exit 1", NewExecOptions().WithRestart(RestartOnFailure, 10*time.Millisecond, 0))
+		for p.Restarts() < 3 {
+			time.Sleep(10 * time.Millisecond)
+		}
+		p.Stop(0)
+		restarts := p.Restarts()
+		time.Sleep(50 * time.Millisecond)
+		if p.Running() || p.Restarts() != restarts {
+			t.Errorf("expected no restarts after Stop, got %d instead of %d", p.Restarts(), restarts)
+		}
+	})
+}
+
+func TestProcessStart(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("requires /bin/sh")
+	}
+	t.Run("stream", func(t *testing.T) {
+		opts := NewExecOptions("-c", "echo out; echo err >&2; exit 1").WithStream().WithRestart(RestartOnFailure, 10*time.Millisecond, 2)
+		p, err := File("/bin/sh").Start(context.Background(), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stdout := make(chan []byte)
+		go func() {
+			b, _ := io.ReadAll(p.Stdout())
+			stdout <- b
+		}()
+		// both pipes are only closed after the last run
+		stderr, err := io.ReadAll(p.Stderr())
+		if err != nil || string(stderr) != "err\nerr\nerr\n" {
+			t.Errorf("expected the stderr of all 3 runs, got %q, %v", stderr, err)
+		}
+		if b := <-stdout; string(b) != "out\nout\nout\n" {
+			t.Errorf("expected the stdout of all 3 runs, got %q", b)
+		}
+		if res, _ := p.Wait(); res.ExitCode != 1 || p.Restarts() != 2 {
+			t.Errorf("unexpected result %s after %d restarts", res, p.Restarts())
+		}
+	})
+	t.Run("pid file", func(t *testing.T) {
+		script := File(filepath.Join(t.TempDir(), "sleeper"))
+		if err := os.WriteFile(script.Path(), []byte("#!/bin/sh\nexec sleep 10\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		p, err := script.Start(context.Background(), NewExecOptions().WithPidFile())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b, err := os.ReadFile(script.Path() + ".pid"); err != nil || string(b) != strconv.Itoa(p.Pid())+"\n" {
+			t.Errorf("expected the pid file to contain %d, got %q, %v", p.Pid(), b, err)
+		}
+		p.Stop(0)
+		if _, err := os.Stat(script.Path() + ".pid"); !os.IsNotExist(err) {
+			t.Errorf("expected the pid file to be removed, got %v", err)
+		}
+	})
+}
+
+func TestInterpreter(t *testing.T) {
+	dir := t.TempDir()
+	for content, want := range map[string][3]string{
+		"#!/bin/sh\necho":                       {"/bin/sh", "", "true"},
+		"#! /usr/bin/env python3 -u\n":          {"/usr/bin/env", "python3 -u", "true"},
+		"#!/bin/bash\t-e\n":                     {"/bin/bash", "-e", "true"},
+		"#!/bin/sh\r\n":                         {"/bin/sh", "", "true"},
+		"#!/usr/bin/awk  -f  \n":                {"/usr/bin/awk", "-f", "true"},
+		"#!\n":                                  {"", "", "false"},
+		"echo no shebang\n":                     {"", "", "false"},
+		"#!/bin/sh":                             {"/bin/sh", "", "true"},
+		" #!/bin/sh\n":                          {"", "", "false"},
+		"#!/usr/bin/env\tbash\t-e -u\nexit 1\n": {"/usr/bin/env", "bash\t-e -u", "true"},
+	} {
+		f := File(filepath.Join(dir, "script"))
+		if err := f.StoreString(content); err != nil {
+			t.Fatal(err)
+		}
+		path, arg, ok := f.Interpreter()
+		if got := [3]string{path, arg, strconv.FormatBool(ok)}; got != want {
+			t.Errorf("%q: expected %q, got %q", content, want, got)
+		}
+	}
+}
+
+func TestScript(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("requires a shell")
+	}
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	t.Run("shebang fallback", func(t *testing.T) {
+		f := File(filepath.Join(dir, "fallback"))
+		if err := os.WriteFile(f.Path(), []byte("#!/bin/sh -e\necho \"$0 $1\"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if out, err := f.ExecOutput("x"); err != nil || string(out) != f.Path()+" x\n" {
+			t.Errorf("expected the interpreter to run the file, got %q, %v", out, err)
+		}
+		if err := f.StoreString("echo no shebang\n"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.ExecOutput(); err == nil {
+			t.Errorf("expected an error for a file without shebang")
+		}
+		if err := f.StoreString("#!" + filepath.Join(dir, "nope") + "\n"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.ExecOutput(); err == nil {
+			t.Errorf("expected an error for a missing interpreter")
+		}
+	})
+	t.Run("run", func(t *testing.T) {
+		s := Script("sh\t-e", "echo $1; false; echo unreachable")
+		res, err := s.Run(ctx, NewExecOptions("hello"))
+		if err == nil || string(res.Stdout) != "hello\n" || s.File() != nil {
+			t.Errorf("expected the script to stop at the error and be removed, got %q, %v", res.Stdout, err)
+		}
+		s = Script("sh", "#!/bin/cat\nbody").Keep()
+		res, err = s.Run(ctx, nil)
+		if err != nil || string(res.Stdout) != "#!/bin/cat\nbody" || !s.File().Exists() {
+			t.Errorf("expected the own shebang to be used and the script to be kept, got %q, %v", res.Stdout, err)
+		}
+		if err := s.Remove(); err != nil || s.File() != nil {
+			t.Errorf("failed to remove the script: %v", err)
+		}
+		if _, err := Script("flo-no-such-interpreter", "").Run(ctx, nil); err == nil {
+			t.Errorf("expected an error for a missing interpreter")
+		}
+	})
+	t.Run("user namespace", func(t *testing.T) {
+		nobody, err := user.Lookup("nobody")
+		if err != nil {
+			t.Skip(err)
+		}
+		sb := NewSandbox().Isolate().AsUser("nobody")
+		if _, err := File("/bin/sh").ExecWith(ctx, NewExecOptions("-c", "true").WithSandbox(sb)); err != nil {
+			t.Skipf("user namespaces are not available: %v", err)
+		}
+		res, err := Script("sh", "id -u\n").Run(ctx, NewExecOptions().WithSandbox(sb))
+		if err != nil || string(res.Stdout) != nobody.Uid+"\n" {
+			t.Errorf("expected the script to run as nobody, got %v, %v", res, err)
+		}
+	})
+	t.Run("sandbox root", func(t *testing.T) {
+		// a minimal root with a shell and the libraries it needs
+		root := t.TempDir()
+		out, err := exec.Command("ldd", "/bin/sh").Output()
+		if err != nil {
+			t.Skip("ldd is not available")
+		}
+		files := []string{"/bin/sh"}
+		for _, field := range strings.Fields(string(out)) {
+			if filepath.IsAbs(field) {
+				files = append(files, field)
+			}
+		}
+		for _, path := range files {
+			if err := os.MkdirAll(filepath.Join(root, filepath.Dir(path)), 0755); err != nil {
+				t.Fatal(err)
+			}
+			b, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(root, path), b, 0755); err != nil {
+				t.Fatal(err)
+			}
+		}
+		sb := NewSandbox().InRoot(root)
+		if os.Getuid() != 0 {
+			sb.NewUser = true
+		}
+		if _, err := File("/bin/sh").ExecWith(ctx, NewExecOptions("-c", "true").WithSandbox(sb)); err != nil {
+			t.Skipf("can't chroot: %v", err)
+		}
+		res, err := Script("sh", `echo "$0"; test -e /bin/sh && ! test -e `+root).Run(ctx, NewExecOptions().WithSandbox(sb))
+		if err != nil || !strings.HasPrefix(string(res.Stdout), "/flo-script-") {
+			t.Errorf("expected the script to run inside of the root, got %q, %v", res.Stdout, err)
+		}
+		if matches, _ := filepath.Glob(filepath.Join(root, "flo-script-*")); len(matches) > 0 {
+			t.Errorf("expected the script to be removed, got %v", matches)
+		}
+	})
+}
+
+func TestChmodRecursive(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("requires unix permissions")
+	}
+	root := t.TempDir()
+	mkTree(t, root, "sub/file", "sub/exec", "file")
+	modes := map[string]os.FileMode{"": 0700, "sub": 0700, "sub/file": 0600, "sub/exec": 0700, "file": 0640}
+	reset := func() {
+		for p, m := range modes {
+			if err := os.Chmod(filepath.Join(root, p), m); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	check := func(want map[string]os.FileMode) {
+		t.Helper()
+		for p, m := range want {
+			info, err := os.Stat(filepath.Join(root, p))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode().Perm() != m {
+				t.Errorf("%q: expected %v, got %v", p, m, info.Mode().Perm())
+			}
+		}
+	}
+	reset()
+
+	// X only adds execute permissions to directories and files that are already executable
+	opts := DefaultRecursiveOptions()
+	opts.DryRun = true
+	changes, err := Dir(root).ChmodRecursive("u=rwX,go=rX", "u=rwX,go=rX", opts)
+	if err != nil || len(changes) != 5 {
+		t.Fatalf("expected 5 changes, got %v, %v", changes, err)
+	}
+	check(modes) // a dry run changes nothing
+	if _, err := Dir(root).ChmodRecursive("u=rwX,go=rX", "u=rwX,go=rX", nil); err != nil {
+		t.Fatal(err)
+	}
+	check(map[string]os.FileMode{"": 0755, "sub": 0755, "sub/file": 0644, "sub/exec": 0755, "file": 0644})
+
+	// with a Filter, only matching entries are changed and the directory itself isn't
+	reset()
+	filter, err := glob.NewFilter([]string{"sub/*"}, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts = DefaultRecursiveOptions()
+	opts.Walk = DefaultWalkOptions()
+	opts.Walk.Filter = filter
+	if _, err := Dir(root).ChmodRecursive("go+rX", "go+rX", opts); err != nil {
+		t.Fatal(err)
+	}
+	check(map[string]os.FileMode{"": 0700, "sub": 0700, "sub/file": 0644, "sub/exec": 0755, "file": 0640})
+
+	// entries that fail don't stop the others, all errors are combined
+	reset()
+	for _, link := range []string{"dangling1", "sub/dangling2"} {
+		if err := os.Symlink("nope", filepath.Join(root, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	opts = DefaultRecursiveOptions()
+	opts.Dereference = true
+	changes, err = Dir(root).ChmodRecursive("", "o+r", opts)
+	if err == nil || !strings.Contains(err.Error(), "dangling1") || !strings.Contains(err.Error(), "dangling2") {
+		t.Errorf("expected the errors of both links, got %v", err)
+	}
+	if len(changes) != 3 {
+		t.Errorf("expected the other files to be changed, got %v", changes)
+	}
+	check(map[string]os.FileMode{"": 0700, "sub": 0700, "sub/file": 0604, "sub/exec": 0704, "file": 0644})
+}
+
+func TestChownRecursive(t *testing.T) {
+	if runtime.GOOS != "linux" || os.Getuid() != 0 {
+		t.Skip("requires root")
+	}
+	nobody, err := user.Lookup("nobody")
+	if err != nil {
+		t.Skip(err)
+	}
+	uid := atoiOr(nobody.Uid, -1)
+	owner := func(path string, follow bool) int {
+		t.Helper()
+		stat := os.Lstat
+		if follow {
+			stat = os.Stat
+		}
+		info, err := stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return atoiOr(ownership.NewFromFileInfo(path, info).UID(), -1)
+	}
+	for _, dereference := range []bool{false, true} {
+		root, outside := t.TempDir(), filepath.Join(t.TempDir(), "target")
+		mkTree(t, root, "file")
+		if err := os.WriteFile(outside, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		link := filepath.Join(root, "link")
+		if err := os.Symlink(outside, link); err != nil {
+			t.Fatal(err)
+		}
+		opts := DefaultRecursiveOptions()
+		opts.Dereference = dereference
+		if _, err := Dir(root).ChownRecursive(nobody.Uid, "", opts); err != nil {
+			t.Fatal(err)
+		}
+		if owner(root, false) != uid || owner(filepath.Join(root, "file"), false) != uid {
+			t.Errorf("dereference %v: expected the tree to be owned by nobody", dereference)
+		}
+		// without Dereference, the link itself is changed (Lchown), otherwise its target (Chown)
+		if linkChanged := owner(link, false) == uid; linkChanged == dereference {
+			t.Errorf("dereference %v: unexpected owner of the link %d", dereference, owner(link, false))
+		}
+		if targetChanged := owner(outside, true) == uid; targetChanged != dereference {
+			t.Errorf("dereference %v: unexpected owner of the target %d", dereference, owner(outside, true))
+		}
+	}
+}
+
+func TestManifest(t *testing.T) {
+	root := t.TempDir()
+	mkTree(t, root, "a", "d/b")
+	escaped := "n\\\nl" // listed as n\\\nl on a line prefixed with a backslash
+	if err := os.WriteFile(filepath.Join(root, escaped), []byte("n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// golden output of sha256sum, md5sum -b and sha256sum --tag
+	for _, tc := range []struct {
+		format ManifestFormat
+		algo   *codec.Codec
+		want   string
+	}{
+		{ManifestText, codec.SHA256, "" +
+			"ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb  a\n" +
+			"30041bfa97aa2f851079acc3c9f6ff4989d2f8a6bedfd3e575f7ac3154896ebe  d/b\n" +
+			"\\1b16b1df538ba12dc3f97edbb85caa7050d46c148134290feba80f8236c83db9  n\\\\\\nl\n"},
+		{ManifestBinary, codec.MD5, "" +
+			"0cc175b9c0f1b6a831c399e269772661 *a\n" +
+			"1c9d6f96b4af5954cbb43b7ed868758f *d/b\n" +
+			"\\7b8b965ad4bca0e41ab51de7b31363a1 *n\\\\\\nl\n"},
+		{ManifestTagged, codec.SHA256, "" +
+			"SHA256 (a) = ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb\n" +
+			"SHA256 (d/b) = 30041bfa97aa2f851079acc3c9f6ff4989d2f8a6bedfd3e575f7ac3154896ebe\n" +
+			"\\SHA256 (n\\\\\\nl) = 1b16b1df538ba12dc3f97edbb85caa7050d46c148134290feba80f8236c83db9\n"},
+	} {
+		path := filepath.Join(t.TempDir(), "SUMS")
+		opts := DefaultManifestOptions()
+		opts.Format = tc.format
+		if err := Dir(root).WriteManifest(path, tc.algo, opts); err != nil {
+			t.Fatal(err)
+		}
+		if b, err := os.ReadFile(path); err != nil || string(b) != tc.want {
+			t.Errorf("format %d: expected\n%s\ngot\n%s, %v", tc.format, tc.want, b, err)
+		}
+		report, err := Dir(root).VerifyManifest(path)
+		if err != nil || !report.OK() || len(report.Entries) != 3 || report.Entries[2].Name != escaped {
+			t.Errorf("format %d: expected all entries to be OK, got %+v, %v", tc.format, report, err)
+		}
+	}
+
+	// the manifest inside the directory isn't listed
+	path := filepath.Join(root, "SHA256SUMS")
+	if err := Dir(root).WriteManifest(path, codec.SHA256, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "a"), []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(root, "d/b")); err != nil {
+		t.Fatal(err)
+	}
+	mkTree(t, root, "c")
+	report, err := Dir(root).VerifyManifest(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, e := range report.Entries {
+		got = append(got, e.Name+": "+e.Status.String())
+	}
+	want := []string{"a: FAILED", "d/b: MISSING", escaped + ": OK", "c: EXTRA"}
+	if !slices.Equal(got, want) || report.OK() {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestDiffDirs(t *testing.T) {
+	chmod := func(mode os.FileMode) func(t *testing.T, a, b string) {
+		return func(t *testing.T, a, b string) {
+			mkTree(t, a, "f")
+			mkTree(t, b, "f")
+			if err := os.Chmod(filepath.Join(b, "f"), mode); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	// sameSize gives the files in both trees the same size and modification time, but different contents
+	sameSize := func(t *testing.T, a, b string) {
+		mtime := time.Now().Add(-time.Hour)
+		for dir, content := range map[string]string{a: "abc", b: "xyz"} {
+			if err := os.WriteFile(filepath.Join(dir, "f"), []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(filepath.Join(dir, "f"), mtime, mtime); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	logs, err := glob.NewFilter(nil, []string{"*.log"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name  string
+		setup func(t *testing.T, a, b string)
+		opts  func(o *DiffOptions)
+		want  string
+	}{
+		{"same", func(t *testing.T, a, b string) { mkTree(t, a, "f", "d/g"); mkTree(t, b, "f", "d/g") }, nil, ""},
+		{"added", func(t *testing.T, a, b string) { mkTree(t, b, "d/x", "f") }, nil, "+ d\n+ f\n"},
+		{"removed", func(t *testing.T, a, b string) { mkTree(t, a, "d/x", "f") }, nil, "- d\n- f\n"},
+		{"modified", func(t *testing.T, a, b string) {
+			mkTree(t, a, "f")
+			if err := os.WriteFile(filepath.Join(b, "f"), []byte("changed"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}, nil, "M f (modified)\n"},
+		{"mode", chmod(0600), nil, "~ f (mode)\n"},
+		{"mode ignored", chmod(0600), func(o *DiffOptions) { o.Mode = false }, ""},
+		{"owner", func(t *testing.T, a, b string) {
+			if os.Geteuid() != 0 {
+				t.Skip("changing the owner requires root")
+			}
+			mkTree(t, a, "f")
+			mkTree(t, b, "f")
+			if err := os.Lchown(filepath.Join(b, "f"), 65534, 65534); err != nil {
+				t.Fatal(err)
+			}
+		}, nil, "~ f (owner)\n"},
+		{"type", func(t *testing.T, a, b string) { mkTree(t, a, "x"); mkTree(t, b, "x/y") }, nil, "T x (type)\n"},
+		{"link", func(t *testing.T, a, b string) {
+			for dir, target := range map[string]string{a: "1", b: "2"} {
+				if err := os.Symlink(target, filepath.Join(dir, "l")); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}, nil, "M l (modified)\n"},
+		{"same size", sameSize, nil, "M f (modified)\n"},
+		{"quick", sameSize, func(o *DiffOptions) { o.Quick = true }, ""},
+		{"filter", func(t *testing.T, a, b string) { mkTree(t, a, "f.log"); mkTree(t, b, "g.log", "f") }, func(o *DiffOptions) {
+			o.Walk = DefaultWalkOptions()
+			o.Walk.Filter = logs
+		}, "+ f\n"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			a, b := t.TempDir(), t.TempDir()
+			tc.setup(t, a, b)
+			opts := DefaultDiffOptions()
+			if tc.opts != nil {
+				tc.opts(opts)
+			}
+			diff, err := DiffDirs(Dir(a), Dir(b), opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := diff.String(); got != tc.want {
+				t.Errorf("expected\n%s\ngot\n%s", tc.want, got)
+			}
+			if diff.Empty() != (tc.want == "") {
+				t.Errorf("expected Empty() to be %v", tc.want == "")
+			}
+		})
+	}
+
+	t.Run("renderers", func(t *testing.T) {
+		a, b := t.TempDir(), t.TempDir()
+		mkTree(t, a, "gone", "mod")
+		mkTree(t, b, "new", "mod")
+		if err := os.WriteFile(filepath.Join(b, "mod"), []byte("changed"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(filepath.Join(b, "mod"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		opts := DefaultDiffOptions()
+		opts.Owner = false
+		diff, err := DiffDirs(Dir(a), Dir(b), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var shape struct {
+			Changes []struct {
+				Path    string   `json:"path"`
+				Changes []string `json:"changes"`
+			} `json:"changes"`
+		}
+		if err := json.Unmarshal([]byte(diff.JSON()), &shape); err != nil {
+			t.Fatal(err)
+		}
+		got := []string{}
+		for _, ch := range shape.Changes {
+			got = append(got, ch.Path+":"+strings.Join(ch.Changes, ","))
+		}
+		if want := []string{"gone:removed", "mod:modified,mode", "new:added"}; !slices.Equal(got, want) {
+			t.Errorf("expected JSON changes %q, got %q from %s", want, got, diff.JSON())
+		}
+
+		lines := strings.Split(strings.TrimSuffix(glog.StripANSI(diff.Colored()), "\n"), "\n")
+		if len(lines) != 3 {
+			t.Fatalf("expected 3 lines, got %q", lines)
+		}
+		for i, prefix := range []string{"- ", "M ", "+ "} {
+			if !strings.HasPrefix(lines[i], prefix) || !strings.Contains(lines[i], shape.Changes[i].Path) {
+				t.Errorf("expected line %d to start with %q and contain %s, got %q", i, prefix, shape.Changes[i].Path, lines[i])
+			}
+		}
+		if !strings.HasSuffix(lines[1], "(modified,mode)") {
+			t.Errorf("expected the kinds at the end of %q", lines[1])
+		}
+	})
+}
+
+func TestDiskUsage(t *testing.T) {
+	root := t.TempDir()
+	mkTree(t, root, "a/1", "a/b/2", "f")
+	if err := os.Link(filepath.Join(root, "f"), filepath.Join(root, "a/link")); err != nil {
+		t.Fatal(err)
+	}
+	names := func(u *DiskUsage) []string {
+		res := []string{}
+		for _, c := range u.Children {
+			res = append(res, c.Name)
+		}
+		return res
+	}
+
+	u, err := Dir(root).DiskUsage(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Files != 4 || u.Dirs != 3 {
+		t.Errorf("expected 4 files and 3 directories, got %d and %d", u.Files, u.Dirs)
+	}
+	if got := names(u); !slices.Equal(got, []string{"a", "f"}) {
+		t.Errorf("unexpected children %q", got)
+	}
+	if got := names(u.Children[0]); !slices.Equal(got, []string{"1", "b", "link"}) {
+		t.Errorf("unexpected children of a %q", got)
+	}
+	if got := names(u.Children[0].Children[1]); !slices.Equal(got, []string{"2"}) {
+		t.Errorf("unexpected children of a/b %q", got)
+	}
+	if info, err := os.Stat(root); err != nil || u.Size != info.Size()+u.Children[0].Size+u.Children[1].Size {
+		t.Errorf("expected the sizes of the children to add up to the total %d, %v", u.Size, err)
+	}
+
+	opts := DefaultDiskUsageOptions()
+	opts.CountLinks = true
+	all, err := Dir(root).DiskUsage(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if all.Files != u.Files || all.Size != u.Size+1 {
+		t.Errorf("expected the hardlink to add the size of f once, got %d vs. %d", all.Size, u.Size)
+	}
+
+	opts = DefaultDiskUsageOptions()
+	opts.MaxDepth = 0
+	shallow, err := Dir(root).DiskUsage(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(names(shallow), []string{"a", "f"}) || shallow.Children[0].Children != nil {
+		t.Errorf("expected only the direct children to be broken down, got %q and %q", names(shallow), names(shallow.Children[0]))
+	}
+	if shallow.Size != u.Size || shallow.Files != u.Files || shallow.Dirs != u.Dirs {
+		t.Errorf("expected everything to be counted, got %+v", shallow)
+	}
+
+	t.Run("one file system", func(t *testing.T) {
+		mnt := filepath.Join(root, "mnt")
+		mkTree(t, root, "mnt/")
+		if out, err := exec.Command("mount", "-t", "tmpfs", "tmpfs", mnt).CombinedOutput(); err != nil {
+			t.Skipf("can't mount a tmpfs: %v, %s", err, out)
+		}
+		t.Cleanup(func() { exec.Command("umount", mnt).Run() })
+		mkTree(t, mnt, "x")
+		opts := DefaultDiskUsageOptions()
+		u, err := Dir(root).DiskUsage(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.Files != 5 || !slices.Contains(names(u), "mnt") {
+			t.Errorf("expected the mount to be counted, got %d files and children %q", u.Files, names(u))
+		}
+		opts.OneFileSystem = true
+		if u, err = Dir(root).DiskUsage(opts); err != nil {
+			t.Fatal(err)
+		}
+		if u.Files != 4 || u.Dirs != 3 || slices.Contains(names(u), "mnt") {
+			t.Errorf("expected the mount to be skipped, got %d files, %d directories and children %q", u.Files, u.Dirs, names(u))
+		}
+	})
+}
+
+func TestWalkFollow(t *testing.T) {
+	root := t.TempDir()
+	mkTree(t, root, "d/x")
+	link := filepath.Join(t.TempDir(), "root")
+	for l, target := range map[string]string{"d/up": "..", "dangling": "nope", "dirlink": "d", link: root} {
+		if !filepath.IsAbs(l) {
+			l = filepath.Join(root, l)
+		}
+		if err := os.Symlink(target, l); err != nil {
+			t.Fatal(err)
+		}
+	}
+	walk := func(dir string, mode FollowMode) (visited, failed []string) {
+		opts := DefaultWalkOptions()
+		opts.Follow = mode
+		for f, err := range Dir(dir).Walk(opts) {
+			rel, _ := filepath.Rel(dir, f.Path())
+			if err != nil {
+				failed = append(failed, rel)
+				continue
+			}
+			if rel == "dangling" && f.Type() != TypeDanglingLink {
+				t.Errorf("mode %d: expected a dangling link, got %v", mode, f.Type())
+			}
+			if visited = append(visited, rel); len(visited) > 100 {
+				t.Fatalf("mode %d: the walk doesn't end: %q", mode, visited)
+			}
+		}
+		slices.Sort(visited)
+		return visited, failed
+	}
+	all := []string{"d", "d/up", "d/x", "dangling", "dirlink"}
+	for _, tc := range []struct {
+		dir             string
+		mode            FollowMode
+		visited, failed []string
+	}{
+		{root, FollowNever, all, nil},
+		{link, FollowNever, nil, nil},
+		{link, FollowRoot, all, nil},
+		{link, FollowAlways, append(all, "dirlink/up", "dirlink/x"), []string{"d/up", "dirlink/up"}},
+	} {
+		visited, failed := walk(tc.dir, tc.mode)
+		if !slices.Equal(visited, tc.visited) {
+			t.Errorf("%s, mode %d: expected %q to be visited, got %q", tc.dir, tc.mode, tc.visited, visited)
+		}
+		slices.Sort(failed)
+		if !slices.Equal(failed, tc.failed) {
+			t.Errorf("%s, mode %d: expected cycles at %q, got %q", tc.dir, tc.mode, tc.failed, failed)
+		}
+	}
+
+	resolvedRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name, readlink, resolved string
+		typ                      FileType
+	}{
+		{"d/up", "..", resolvedRoot, TypeSymlink},
+		{"dirlink", "d", filepath.Join(resolvedRoot, "d"), TypeSymlink},
+		{"dangling", "nope", "", TypeDanglingLink},
+		{"d/x", "", filepath.Join(root, "d/x"), TypeFile},
+	} {
+		f := File(filepath.Join(root, tc.name))
+		if typ := f.Type(); typ != tc.typ {
+			t.Errorf("%s: expected type %v, got %v", tc.name, tc.typ, typ)
+		}
+		if target, err := f.Readlink(); target != tc.readlink || (err != nil) != (tc.readlink == "") {
+			t.Errorf("%s: expected the link to be %q, got %q, %v", tc.name, tc.readlink, target, err)
+		}
+		resolved, err := f.ResolveLink()
+		if (err != nil) != (tc.resolved == "") || (err == nil && resolved.Path() != tc.resolved) {
+			t.Errorf("%s: expected the link to resolve to %q, got %v, %v", tc.name, tc.resolved, resolved, err)
+		}
+	}
+}
+
+func TestIterators(t *testing.T) {
+	root := t.TempDir()
+	mkTree(t, root, "1", "2", "3", "a/4", "a/b/5", "c/6")
+
+	t.Run("break", func(t *testing.T) {
+		n := 0
+		for range Dir(root).Entries() {
+			if n++; n == 2 {
+				break
+			}
+		}
+		for range Dir(root).Walk(nil) {
+			if n++; n == 5 {
+				break
+			}
+		}
+		for range Dir(root).All() {
+			if n++; n == 8 {
+				break
+			}
+		}
+		if n != 8 {
+			t.Errorf("expected the iterators to stop, got %d entries", n)
+		}
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		missing := filepath.Join(root, "missing")
+		for f, err := range Dir(missing).Entries() {
+			if err == nil || f.Path() != missing {
+				t.Errorf("expected the directory to be yielded with an error, got %s, %v", f.Path(), err)
+			}
+		}
+	})
+
+	t.Run("unreadable", func(t *testing.T) {
+		if os.Geteuid() == 0 {
+			t.Skip("root can read any directory")
+		}
+		locked := filepath.Join(root, "locked")
+		mkTree(t, root, "locked/7")
+		if err := os.Chmod(locked, 0); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { os.Chmod(locked, 0755) })
+		visited, failed := 0, []string{}
+		for f, err := range Dir(root).Walk(nil) {
+			if err != nil {
+				failed = append(failed, f.Path())
+				continue
+			}
+			visited++
+		}
+		if !slices.Equal(failed, []string{locked}) || visited != 10 {
+			t.Errorf("expected the locked directory to be yielded with an error after 10 entries, got %q after %d", failed, visited)
+		}
+		n := 0
+		for range Dir(root).All() {
+			n++
+		}
+		if n != visited {
+			t.Errorf("expected All to skip the error, got %d entries", n)
+		}
+	})
+}
@@ -0,0 +1,237 @@
+package flo
+
+import (
+	"context"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	c "github.com/toxyl/flo/codec"
+)
+
+// RestartPolicy decides whether a Process is restarted after it has exited.
+type RestartPolicy int
+
+const (
+	RestartNever     RestartPolicy = iota // the process is never restarted
+	RestartOnFailure                      // the process is restarted if it didn't exit successfully
+	RestartAlways                         // the process is restarted whenever it exits
+)
+
+func (r RestartPolicy) String() string {
+	switch r {
+	case RestartOnFailure:
+		return "on-failure"
+	case RestartAlways:
+		return "always"
+	}
+	return "never"
+}
+
+// Process is a process started in the background, see FileObj.Start.
+// If the process is restarted, the Process refers to the current instance.
+type Process struct {
+	file     *FileObj
+	opts     *ExecOptions
+	ctx      context.Context
+	mu       sync.Mutex
+	run      *execRun
+	restarts int
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+	res      *ExecResult
+	err      error
+
+	stdoutR, stderrR *io.PipeReader
+	stdoutW, stderrW *io.PipeWriter
+}
+
+// Start runs the file in the background using `opts`, if `opts` is nil, NewExecOptions() are used.
+// Cancelling `ctx` stops the process (see ExecWith) and prevents further restarts.
+func (f *FileObj) Start(ctx context.Context, opts *ExecOptions) (*Process, error) {
+	if opts == nil {
+		opts = NewExecOptions()
+	}
+	p := &Process{
+		file: f,
+		opts: opts,
+		ctx:  ctx,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	runOpts := *opts
+	if opts.Stream {
+		p.stdoutR, p.stdoutW = io.Pipe()
+		p.stderrR, p.stderrW = io.Pipe()
+		runOpts.Stdout = streamTo(opts.Stdout, p.stdoutW)
+		runOpts.Stderr = streamTo(opts.Stderr, p.stderrW)
+	}
+	p.opts = &runOpts
+	r, err := f.startCmd(ctx, p.opts)
+	if err != nil {
+		p.closeStreams()
+		return nil, err
+	}
+	p.run = r
+	p.writePidFile()
+	go p.supervise()
+	return p, nil
+}
+
+func streamTo(w io.Writer, pipe *io.PipeWriter) io.Writer {
+	if w == nil {
+		return pipe
+	}
+	return io.MultiWriter(w, pipe)
+}
+
+// supervise waits for the process and restarts it according to the restart policy.
+func (p *Process) supervise() {
+	defer close(p.done)
+	defer p.closeStreams()
+	defer p.removePidFile()
+	for {
+		p.mu.Lock()
+		r := p.run
+		p.mu.Unlock()
+
+		res, err := r.wait()
+
+		p.mu.Lock()
+		p.res, p.err = res, err
+		p.mu.Unlock()
+
+		if !p.shouldRestart(res) {
+			return
+		}
+		select {
+		case <-time.After(p.opts.RestartDelay):
+		case <-p.stop:
+			return
+		case <-p.ctx.Done():
+			return
+		}
+
+		p.mu.Lock()
+		select {
+		case <-p.stop:
+			p.mu.Unlock()
+			return
+		default:
+		}
+		next, err := p.file.startCmd(p.ctx, p.opts)
+		if err != nil {
+			p.err = err
+			p.mu.Unlock()
+			return
+		}
+		p.run = next
+		p.restarts++
+		p.mu.Unlock()
+		p.writePidFile()
+	}
+}
+
+func (p *Process) shouldRestart(res *ExecResult) bool {
+	if p.ctx.Err() != nil || (p.opts.MaxRestarts > 0 && p.restarts >= p.opts.MaxRestarts) {
+		return false
+	}
+	select {
+	case <-p.stop:
+		return false
+	default:
+	}
+	switch p.opts.Restart {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return !res.Success()
+	}
+	return false
+}
+
+func (p *Process) closeStreams() {
+	if p.stdoutW != nil {
+		p.stdoutW.Close()
+		p.stderrW.Close()
+	}
+}
+
+// PidFile returns the file the pid is written to.
+func (p *Process) PidFile() *FileObj { return File(p.file.Path() + ".pid") }
+
+func (p *Process) writePidFile() {
+	if p.opts.PidFile {
+		_ = p.PidFile().StoreWith(c.STRING, strconv.Itoa(p.Pid())+"\n", true)
+	}
+}
+
+func (p *Process) removePidFile() {
+	if p.opts.PidFile {
+		_ = p.PidFile().Remove()
+	}
+}
+
+// Pid returns the pid of the current instance of the process.
+func (p *Process) Pid() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.run.cmd.Process.Pid
+}
+
+// Restarts returns how often the process has been restarted.
+func (p *Process) Restarts() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.restarts
+}
+
+// Running returns true until the process has exited and won't be restarted anymore.
+func (p *Process) Running() bool {
+	select {
+	case <-p.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// Stdout returns the live output of the process, it is only available if ExecOptions.Stream is set
+// and must be read, otherwise the process blocks when writing. It is closed once the process has exited
+// and won't be restarted anymore.
+func (p *Process) Stdout() io.Reader { return p.stdoutR }
+
+// Stderr works like Stdout, but for the error output.
+func (p *Process) Stderr() io.Reader { return p.stderrR }
+
+// Signal sends `sig` to the current instance of the process (or its process group).
+// It returns os.ErrProcessDone if the instance has already exited.
+func (p *Process) Signal(sig os.Signal) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.run.exited() {
+		return os.ErrProcessDone
+	}
+	return signal(p.run.cmd.Process, sig, p.opts.ProcessGroup)
+}
+
+// Wait waits until the process has exited and won't be restarted anymore and returns the result of the last run.
+func (p *Process) Wait() (*ExecResult, error) {
+	<-p.done
+	return p.res, p.err
+}
+
+// Stop prevents further restarts and asks the process to terminate (SIGTERM on linux).
+// If it is still running after `grace`, it is killed, 0 kills it right away. Processes that have
+// already exited are left alone, so a pid that has been reused isn't signalled.
+func (p *Process) Stop(grace time.Duration) (*ExecResult, error) {
+	p.stopOnce.Do(func() { close(p.stop) })
+	p.mu.Lock()
+	r := p.run
+	p.mu.Unlock()
+	_ = r.stop(grace)
+	return p.Wait()
+}
@@ -0,0 +1,209 @@
+package flo
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+
+	"github.com/toxyl/flo/errors"
+	"github.com/toxyl/flo/ownership"
+	"github.com/toxyl/flo/permissions"
+)
+
+type RecursiveOptions struct {
+	// Walk selects the entries that are changed (e.g. using Filter and IgnoreFiles). If nil, all entries are.
+	// The directory itself is only changed if no Filter is set.
+	Walk *WalkOptions
+	// DryRun only returns the changes that would be made.
+	DryRun bool
+	// Dereference changes the targets of links instead of the links themselves. Otherwise the owner
+	// of links is changed with Lchown and their permissions are left alone, because links have none.
+	Dereference bool
+}
+
+func DefaultRecursiveOptions() *RecursiveOptions {
+	return &RecursiveOptions{
+		Walk:        nil,
+		DryRun:      false,
+		Dereference: false,
+	}
+}
+
+// AttrChange is a change of the permissions or the owner of an entry made by ChmodRecursive or ChownRecursive.
+type AttrChange struct {
+	Path             string
+	OldMode, NewMode fs.FileMode // only set by ChmodRecursive
+	OldUID, NewUID   int         // only set by ChownRecursive, -1 if unknown
+	OldGID, NewGID   int         // only set by ChownRecursive, -1 if unknown
+	Err              error       // set if the change failed
+}
+
+func (c *AttrChange) String() string {
+	s := ""
+	if c.OldMode != c.NewMode {
+		s = fmt.Sprintf("mode of %s changed from %s to %s", c.Path, c.OldMode, c.NewMode)
+	} else {
+		s = fmt.Sprintf("owner of %s changed from %d:%d to %d:%d", c.Path, c.OldUID, c.OldGID, c.NewUID, c.NewGID)
+	}
+	if c.Err != nil {
+		s += " (failed: " + c.Err.Error() + ")"
+	}
+	return s
+}
+
+// recursiveEntry is an entry visited by ChmodRecursive or ChownRecursive.
+type recursiveEntry struct {
+	path string
+	info fs.FileInfo // of the link target if dereferenced
+	link bool        // a link that isn't dereferenced
+}
+
+// eachRecursive calls `fn` for the directory and every entry below it selected by `opts`.
+// Errors returned by `fn` and encountered while walking are combined, the walk never stops early.
+func (d *DirObj) eachRecursive(opts *RecursiveOptions, fn func(e recursiveEntry) error) error {
+	if opts == nil {
+		opts = DefaultRecursiveOptions()
+	}
+	if !opts.DryRun {
+		defer d.invalidate()
+	}
+	errs := []error{}
+	visit := func(path string) {
+		stat := os.Lstat
+		if opts.Dereference {
+			stat = os.Stat
+		}
+		info, err := stat(path)
+		if err != nil {
+			errs = append(errs, errors.ErrFile("stat", path, err))
+			return
+		}
+		errs = append(errs, fn(recursiveEntry{path: path, info: info, link: info.Mode()&fs.ModeSymlink != 0}))
+	}
+	if opts.Walk == nil || opts.Walk.Filter == nil {
+		visit(d.Path())
+	}
+	for f, err := range d.Walk(opts.Walk) {
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		visit(f.Path())
+	}
+	return errors.Combine(errs...)
+}
+
+// ChmodRecursive changes the permissions of the directory and everything below it using chmod
+// expressions (see permissions.Parse), `dirMode` for directories and `fileMode` for everything else.
+// An empty expression leaves those entries alone. Use "X" to only add execute permissions to directories
+// and files that are already executable, e.g. "u=rwX,go=rX" for both.
+// Errors don't stop the changes, they are combined and also recorded in the affected changes.
+// Only entries whose permissions (would) change are returned.
+func (d *DirObj) ChmodRecursive(dirMode, fileMode string, opts *RecursiveOptions) ([]*AttrChange, error) {
+	modes := map[bool]*permissions.Mode{}
+	for isDir, expr := range map[bool]string{true: dirMode, false: fileMode} {
+		if expr == "" {
+			continue
+		}
+		m, err := permissions.Parse(expr)
+		if err != nil {
+			return nil, err
+		}
+		modes[isDir] = m
+	}
+	if opts == nil {
+		opts = DefaultRecursiveOptions()
+	}
+	umask := permissions.Umask()
+	changes := []*AttrChange{}
+	err := d.eachRecursive(opts, func(e recursiveEntry) error {
+		m := modes[e.info.IsDir()]
+		if m == nil || e.link {
+			return nil
+		}
+		c := &AttrChange{
+			Path:    e.path,
+			OldMode: e.info.Mode(),
+			NewMode: m.Apply(e.info.Mode(), umask),
+			OldUID:  -1,
+			NewUID:  -1,
+			OldGID:  -1,
+			NewGID:  -1,
+		}
+		if c.NewMode == c.OldMode {
+			return nil
+		}
+		changes = append(changes, c)
+		if !opts.DryRun {
+			if err := os.Chmod(e.path, c.NewMode); err != nil {
+				c.Err = errors.ErrFailedToSetPermissions(e.path, c.NewMode, err)
+			}
+		}
+		return c.Err
+	})
+	return changes, err
+}
+
+// ChownRecursive changes the owner of the directory and everything below it. `user` and `group` can be
+// names or ids, if either is empty, it is left alone.
+// Errors don't stop the changes, they are combined and also recorded in the affected changes.
+// Only entries whose owner (would) change are returned.
+func (d *DirObj) ChownRecursive(user, group string, opts *RecursiveOptions) ([]*AttrChange, error) {
+	uid, gid := -1, -1
+	if user != "" {
+		var err error
+		if uid, _, err = lookupUser(user); err != nil {
+			return nil, err
+		}
+	}
+	if group != "" {
+		var err error
+		if gid, err = lookupGroup(group); err != nil {
+			return nil, err
+		}
+	}
+	if opts == nil {
+		opts = DefaultRecursiveOptions()
+	}
+	changes := []*AttrChange{}
+	err := d.eachRecursive(opts, func(e recursiveEntry) error {
+		o := ownership.NewFromFileInfo(e.path, e.info)
+		c := &AttrChange{
+			Path:    e.path,
+			OldMode: e.info.Mode(),
+			NewMode: e.info.Mode(),
+			OldUID:  atoiOr(o.UID(), -1),
+			OldGID:  atoiOr(o.GID(), -1),
+		}
+		c.NewUID, c.NewGID = c.OldUID, c.OldGID
+		if uid >= 0 {
+			c.NewUID = uid
+		}
+		if gid >= 0 {
+			c.NewGID = gid
+		}
+		if c.NewUID == c.OldUID && c.NewGID == c.OldGID {
+			return nil
+		}
+		changes = append(changes, c)
+		if !opts.DryRun {
+			chown := os.Chown
+			if e.link {
+				chown = os.Lchown
+			}
+			if err := chown(e.path, uid, gid); err != nil {
+				c.Err = errors.ErrFailedToSetOwner(e.path, err)
+			}
+		}
+		return c.Err
+	})
+	return changes, err
+}
+
+func atoiOr(s string, fallback int) int {
+	if v, err := strconv.Atoi(s); err == nil {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,80 @@
+package flo
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Sandbox restricts a process started with ExecWith, Start or a Pipeline (linux only).
+// The zero value doesn't restrict anything, use the builder methods to add restrictions.
+// Resource limits are applied after exec, but before the program runs, which requires that the
+// process can be traced (see ptrace). To limit a process that runs as a different user,
+// CAP_SYS_RESOURCE is required.
+type Sandbox struct {
+	// User and Group are the user and group (names or ids) the process runs as.
+	// If only User is set, its primary group is used.
+	User, Group string
+	// CPU limits the CPU time of the process (RLIMIT_CPU), 0 means unlimited.
+	CPU time.Duration
+	// Memory limits the address space of the process in bytes (RLIMIT_AS), 0 means unlimited.
+	Memory uint64
+	// Files limits the number of open files of the process (RLIMIT_NOFILE), 0 means unlimited.
+	Files uint64
+	// NoNewPrivileges prevents the process from gaining privileges, e.g. through setuid binaries.
+	NoNewPrivileges bool
+	// Root is the directory the process is chrooted to, the executable and the working directory
+	// are resolved inside of it.
+	Root string
+	// NewUser runs the process in a new user namespace, which allows unprivileged users to create
+	// the other namespaces. The process runs as root (or User) inside of it.
+	NewUser bool
+	// NewMount, NewNetwork and NewPID run the process in new mount, network and PID namespaces.
+	NewMount, NewNetwork, NewPID bool
+}
+
+func NewSandbox() *Sandbox { return &Sandbox{} }
+
+func (s *Sandbox) AsUser(user string) *Sandbox   { s.User = user; return s }
+func (s *Sandbox) AsGroup(group string) *Sandbox { s.Group = group; return s }
+func (s *Sandbox) LimitCPU(d time.Duration) *Sandbox {
+	s.CPU = d
+	return s
+}
+func (s *Sandbox) LimitMemory(bytes uint64) *Sandbox { s.Memory = bytes; return s }
+func (s *Sandbox) LimitFiles(n uint64) *Sandbox      { s.Files = n; return s }
+func (s *Sandbox) WithNoNewPrivileges() *Sandbox     { s.NoNewPrivileges = true; return s }
+func (s *Sandbox) InRoot(dir string) *Sandbox        { s.Root = dir; return s }
+
+// Isolate runs the process in new user, mount, network and PID namespaces.
+func (s *Sandbox) Isolate() *Sandbox {
+	s.NewUser, s.NewMount, s.NewNetwork, s.NewPID = true, true, true, true
+	return s
+}
+
+// executable returns the file that is executed when running `f`, taking the root into account.
+func (s *Sandbox) executable(f *FileObj) *FileObj {
+	if s == nil || s.Root == "" {
+		return f
+	}
+	return File(filepath.Join(s.Root, f.Path()))
+}
+
+// credentials resolves User and Group, `ok` is false if neither is set.
+func (s *Sandbox) credentials() (uid, gid int, ok bool, err error) {
+	if s.User == "" && s.Group == "" {
+		return 0, 0, false, nil
+	}
+	uid, gid = os.Getuid(), os.Getgid()
+	if s.User != "" {
+		if uid, gid, err = lookupUser(s.User); err != nil {
+			return 0, 0, false, err
+		}
+	}
+	if s.Group != "" {
+		if gid, err = lookupGroup(s.Group); err != nil {
+			return 0, 0, false, err
+		}
+	}
+	return uid, gid, true, nil
+}
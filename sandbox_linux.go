@@ -0,0 +1,137 @@
+//go:build linux
+
+package flo
+
+import (
+	"os/exec"
+	"runtime"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// prSetNoNewPrivs is the prctl option to set the no_new_privs flag of the calling thread.
+const prSetNoNewPrivs = 38
+
+// apply configures `cmd` to run inside the sandbox.
+func (s *Sandbox) apply(cmd *exec.Cmd) error {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	attr := cmd.SysProcAttr
+	uid, gid, ok, err := s.credentials()
+	if err != nil {
+		return err
+	}
+	if s.NewUser {
+		// map the chosen ids (or root) inside the namespace to our own ids outside of it
+		if !ok {
+			uid, gid = 0, 0
+		}
+		attr.Cloneflags |= syscall.CLONE_NEWUSER
+		attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: uid, HostID: syscall.Getuid(), Size: 1}}
+		attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: gid, HostID: syscall.Getgid(), Size: 1}}
+		attr.GidMappingsEnableSetgroups = false
+		attr.Credential = &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid), NoSetGroups: true}
+	} else if ok {
+		attr.Credential = &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid), Groups: []uint32{}}
+	}
+	if s.NewMount {
+		attr.Cloneflags |= syscall.CLONE_NEWNS
+	}
+	if s.NewNetwork {
+		attr.Cloneflags |= syscall.CLONE_NEWNET
+	}
+	if s.NewPID {
+		attr.Cloneflags |= syscall.CLONE_NEWPID
+	}
+	if s.Root != "" {
+		attr.Chroot = s.Root
+		if cmd.Dir == "" {
+			// otherwise the process would start outside of the root
+			cmd.Dir = "/"
+		}
+	}
+	return nil
+}
+
+// start starts `cmd` with the limits of the sandbox applied. To apply them before the program runs,
+// the child is traced, which stops it right after exec. The limits are set while it is stopped,
+// then it is detached and continues. With NoNewPrivileges the flag is set on the starting thread,
+// which the child inherits it from. That thread is discarded afterwards, because the flag can't be unset.
+func (s *Sandbox) start(cmd *exec.Cmd) error {
+	limited := s.CPU > 0 || s.Memory > 0 || s.Files > 0
+	if !limited && !s.NoNewPrivileges {
+		return cmd.Start()
+	}
+	if limited {
+		cmd.SysProcAttr.Ptrace = true
+	}
+	res := make(chan error, 1)
+	go func() {
+		// the tracer is the thread, so starting and detaching have to happen on the same one
+		runtime.LockOSThread()
+		if s.NoNewPrivileges {
+			// the goroutine exits without unlocking, so the runtime terminates the thread
+			if _, _, errno := syscall.RawSyscall6(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0, 0, 0, 0); errno != 0 {
+				res <- errno
+				return
+			}
+		} else {
+			defer runtime.UnlockOSThread()
+		}
+		if err := cmd.Start(); err != nil || !limited {
+			res <- err
+			return
+		}
+		res <- s.limitStopped(cmd)
+	}()
+	return <-res
+}
+
+// limitStopped waits for the traced child to stop after exec, sets the limits and detaches from it.
+// If that fails, the child is killed before it runs.
+func (s *Sandbox) limitStopped(cmd *exec.Cmd) error {
+	pid := cmd.Process.Pid
+	var ws syscall.WaitStatus
+	for {
+		_, err := syscall.Wait4(pid, &ws, syscall.WALL, nil)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err == nil && !ws.Stopped() {
+			err = syscall.ECHILD
+		}
+		if err == nil {
+			err = s.limit(pid)
+		}
+		if err == nil {
+			err = syscall.PtraceDetach(pid)
+		}
+		if err != nil {
+			_ = cmd.Process.Kill()
+			_ = cmd.Wait()
+		}
+		return err
+	}
+}
+
+// limit sets the resource limits of the process with the given `pid`. Both, the soft and
+// the hard limit are set, so the process can't raise them again.
+func (s *Sandbox) limit(pid int) error {
+	limits := map[int]uint64{
+		syscall.RLIMIT_CPU:    uint64((s.CPU + time.Second - 1) / time.Second), // rounded up to whole seconds
+		syscall.RLIMIT_AS:     s.Memory,
+		syscall.RLIMIT_NOFILE: s.Files,
+	}
+	for resource, limit := range limits {
+		if limit == 0 {
+			continue
+		}
+		rl := syscall.Rlimit{Cur: limit, Max: limit}
+		if _, _, errno := syscall.RawSyscall6(syscall.SYS_PRLIMIT64, uintptr(pid), uintptr(resource), uintptr(unsafe.Pointer(&rl)), 0, 0, 0); errno != 0 {
+			return errno
+		}
+	}
+	return nil
+}
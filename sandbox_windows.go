@@ -0,0 +1,14 @@
+//go:build windows
+
+package flo
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// apply fails, sandboxes are not supported on windows.
+func (s *Sandbox) apply(cmd *exec.Cmd) error { return errors.ErrUnsupported }
+
+// start is never reached, because apply fails.
+func (s *Sandbox) start(cmd *exec.Cmd) error { return errors.ErrUnsupported }
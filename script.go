@@ -0,0 +1,219 @@
+package flo
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/toxyl/flo/errors"
+)
+
+// Interpreter returns the interpreter and its optional argument named in the shebang of the file.
+// Like the kernel does, everything after the interpreter is passed as a single argument.
+func (f *FileObj) Interpreter() (path, arg string, ok bool) {
+	file, err := os.Open(f.Path())
+	if err != nil {
+		return "", "", false
+	}
+	defer file.Close()
+	line, err := bufio.NewReader(file).ReadString('\n')
+	if err != nil && line == "" {
+		return "", "", false
+	}
+	line, found := strings.CutPrefix(strings.TrimRight(line, "\r\n"), "#!")
+	if !found {
+		return "", "", false
+	}
+	path, arg = cutSpace(strings.TrimLeft(line, " \t"))
+	if path == "" {
+		return "", "", false
+	}
+	return path, strings.TrimSpace(arg), true
+}
+
+// cutSpace splits `s` at the first space or tab.
+func cutSpace(s string) (before, after string) {
+	if i := strings.IndexAny(s, " \t"); i >= 0 {
+		return s[:i], s[i+1:]
+	}
+	return s, ""
+}
+
+// execCommand returns the path and arguments to run the file with. If the file isn't executable
+// but has a shebang with an executable interpreter, the interpreter is run instead.
+func (f *FileObj) execCommand(sb *Sandbox, args []string) (string, []string, error) {
+	if sb.executable(f).IsExecutable() {
+		return f.Path(), args, nil
+	}
+	path, arg, ok := sb.executable(f).Interpreter()
+	if !ok || !sb.executable(File(path)).IsExecutable() {
+		return "", nil, errors.ErrIsNotExecutable(f.Path())
+	}
+	res := []string{}
+	if arg != "" {
+		res = append(res, arg)
+	}
+	return path, append(append(res, f.Path()), args...), nil
+}
+
+// ScriptObj is a script that is written to a private temporary location when it is run, see Script.
+type ScriptObj struct {
+	interpreter string
+	body        string
+	keep        bool
+	file        *FileObj
+}
+
+// Script returns a script that runs `body` with `interpreter`, which can be a path or the name
+// of an executable in PATH, optionally followed by an argument, e.g. "bash -e".
+// If `body` has its own shebang, it is used as is. When run in a Sandbox with a Root, the script
+// is created inside of the root and the interpreter is looked up there.
+func Script(interpreter, body string) *ScriptObj {
+	return &ScriptObj{
+		interpreter: interpreter,
+		body:        body,
+		keep:        false,
+	}
+}
+
+// Keep prevents the script from being removed after it has been run, use Remove to remove it.
+func (s *ScriptObj) Keep() *ScriptObj { s.keep = true; return s }
+
+// File returns the file of the script, nil if it hasn't been created yet.
+func (s *ScriptObj) File() *FileObj { return s.file }
+
+// shebang returns the body with the shebang added, the interpreter is looked up inside of `root`.
+func (s *ScriptObj) shebang(root string) (string, error) {
+	if strings.HasPrefix(s.body, "#!") {
+		return s.body, nil
+	}
+	interpreter, arg := cutSpace(strings.TrimSpace(s.interpreter))
+	path, err := lookPath(root, interpreter)
+	if err != nil {
+		return "", errors.ErrInterpreterNotFound(interpreter, err)
+	}
+	line := "#!" + strings.TrimSpace(path+" "+strings.TrimSpace(arg))
+	return line + "\n" + s.body, nil
+}
+
+// lookPath works like exec.LookPath, but looks up the executable inside of `root` and returns
+// its absolute path as seen from there.
+func lookPath(root, name string) (string, error) {
+	if root == "" {
+		path, err := exec.LookPath(name)
+		if err != nil {
+			return "", err
+		}
+		return filepath.Abs(path)
+	}
+	candidates := []string{name}
+	if !strings.ContainsRune(name, filepath.Separator) {
+		candidates = candidates[:0]
+		for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+			candidates = append(candidates, filepath.Join(dir, name))
+		}
+	}
+	for _, path := range candidates {
+		if filepath.IsAbs(path) && File(filepath.Join(root, path)).IsExecutable() {
+			return path, nil
+		}
+	}
+	return "", exec.ErrNotFound
+}
+
+// Create writes the script to a new private temporary directory, both are only accessible by the owner.
+// If the script has already been created, its file is returned.
+func (s *ScriptObj) Create() (*FileObj, error) { return s.create("") }
+
+// create works like Create, but creates the directory inside of `root`, if it isn't empty.
+func (s *ScriptObj) create(root string) (*FileObj, error) {
+	if s.file != nil {
+		return s.file, nil
+	}
+	body, err := s.shebang(root)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp(root, "flo-script-") // the default temp dir if root is empty
+	if err != nil {
+		return nil, errors.ErrFailedToCreateDir(dir, err)
+	}
+	file := File(filepath.Join(dir, "script"))
+	if err := os.WriteFile(file.Path(), []byte(body), 0700); err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, errors.ErrFailedToCreateFile(file.Path(), err)
+	}
+	s.file = file
+	return file, nil
+}
+
+// Remove removes the script and its temporary directory.
+func (s *ScriptObj) Remove() error {
+	if s.file == nil {
+		return nil
+	}
+	dir := s.file.Parent().Path()
+	if err := os.RemoveAll(dir); err != nil {
+		return errors.ErrFailedToDeleteFile(dir, err)
+	}
+	s.file = nil
+	return nil
+}
+
+// own hands the script over to the user of the sandbox, so it can still be read when running as that user.
+// In a new user namespace the user is mapped to our own uid, which already owns the script.
+func (s *ScriptObj) own(sb *Sandbox) error {
+	if sb == nil || sb.User == "" || sb.NewUser {
+		return nil
+	}
+	uid, gid, err := lookupUser(sb.User)
+	if err != nil {
+		return err
+	}
+	for _, path := range []string{s.file.Parent().Path(), s.file.Path()} {
+		if err := os.Chown(path, uid, gid); err != nil {
+			return errors.ErrFailedToSetOwner(path, err)
+		}
+	}
+	return nil
+}
+
+// Run creates the script, runs it like FileObj.ExecWith does and removes it afterwards, unless it should be kept.
+func (s *ScriptObj) Run(ctx context.Context, opts *ExecOptions) (*ExecResult, error) {
+	if opts == nil {
+		opts = NewExecOptions()
+	}
+	root := ""
+	if opts.Sandbox != nil {
+		root = opts.Sandbox.Root
+	}
+	file, err := s.create(root)
+	if err != nil {
+		return nil, err
+	}
+	if !s.keep {
+		defer func() { _ = s.Remove() }()
+	}
+	if err := s.own(opts.Sandbox); err != nil {
+		return nil, err
+	}
+	if root != "" {
+		// the sandbox resolves the file inside of the root
+		rel, err := filepath.Rel(root, file.Path())
+		if err != nil {
+			return nil, err
+		}
+		file = File(string(filepath.Separator) + rel)
+	}
+	return file.ExecWith(ctx, opts)
+}
+
+// Exec works like Run, but uses the terminal for input and output, just like FileObj.Exec does.
+func (s *ScriptObj) Exec(args ...any) error {
+	opts := NewExecOptions(args...).WithStdin(os.Stdin).WithStdout(os.Stdout).WithStderr(os.Stderr).WithCapture(false)
+	_, err := s.Run(context.Background(), opts)
+	return err
+}
@@ -0,0 +1,91 @@
+package flo
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/toxyl/flo/errors"
+)
+
+// IsSymlink returns true if the file itself is a symlink (as reported by os.Lstat).
+func (f *FileObj) IsSymlink() bool { return f.Permissions().IsLink() }
+
+// IsDanglingLink returns true if the file is a symlink whose target doesn't exist.
+func (f *FileObj) IsDanglingLink() bool { return f.IsSymlink() && !f.Exists() }
+
+// Readlink returns the target of the symlink as stored in the link, i.e. it might be relative.
+func (f *FileObj) Readlink() (string, error) {
+	target, err := os.Readlink(f.Path())
+	if err != nil {
+		return "", errors.ErrFailedToReadLink(f.Path(), err)
+	}
+	return target, nil
+}
+
+// ResolveLink follows the symlink (and all links in its target) and returns the final target.
+// If the file is not a link, the file itself is returned.
+func (f *FileObj) ResolveLink() (*FileObj, error) {
+	if !f.IsSymlink() {
+		return f, nil
+	}
+	target, err := filepath.EvalSymlinks(f.Path())
+	if err != nil {
+		return nil, errors.ErrFailedToReadLink(f.Path(), err)
+	}
+	return File(target), nil
+}
+
+// Type returns the type of the file itself, links are not followed.
+func (f *FileObj) Type() FileType {
+	p := f.Permissions()
+	switch {
+	case f.IsDanglingLink():
+		return TypeDanglingLink
+	case p.IsLink():
+		return TypeSymlink
+	case p.IsDir():
+		return TypeDir
+	case p.IsFIFO():
+		return TypeFIFO
+	case p.IsSocket():
+		return TypeSocket
+	case p.IsCharDevice():
+		return TypeCharDevice
+	case p.IsBlockDevice():
+		return TypeBlockDevice
+	}
+	return TypeFile
+}
+
+// ancestors is the chain of directories from the walked directory down to the current one,
+// it is used to detect symlinks that lead back to a directory we are already in.
+type ancestors struct {
+	path   string
+	parent *ancestors
+}
+
+func (a *ancestors) push(path string) *ancestors {
+	return &ancestors{path: path, parent: a}
+}
+
+// cycle returns true if `path` is the same directory (by device and inode) as one of the ancestors.
+func (a *ancestors) cycle(path string) bool {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	for n := a; n != nil; n = n.parent {
+		if s, err := os.Stat(n.path); err == nil && os.SameFile(stat, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// check returns errors.ErrSymlinkCycle if `d` is a link to one of the ancestors.
+func (a *ancestors) check(d *DirObj) error {
+	if d.IsSymlink() && a.cycle(d.Path()) {
+		return errors.ErrSymlinkCycle(d.Path())
+	}
+	return nil
+}
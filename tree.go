@@ -0,0 +1,215 @@
+package flo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/toxyl/flo/checksum"
+	c "github.com/toxyl/flo/codec"
+	"github.com/toxyl/flo/errors"
+	"github.com/toxyl/flo/ignore"
+	"github.com/toxyl/flo/permissions"
+)
+
+type TreeOptions struct {
+	// Permissions includes the permission bits (including setuid, setgid and sticky) in the digest.
+	Permissions bool
+	// Ownership includes the numeric user and group IDs in the digest.
+	Ownership bool
+	// Links hashes symlinks by their target path instead of following them.
+	// Otherwise links to files are followed, other links (e.g. to directories) are still hashed by their target path.
+	Links bool
+	// Walk limits the tree to files matching its Filter and IgnoreFiles, other fields are not used.
+	Walk *WalkOptions
+}
+
+func DefaultTreeOptions() *TreeOptions {
+	return &TreeOptions{
+		Permissions: false,
+		Ownership:   false,
+		Links:       true,
+		Walk:        nil,
+	}
+}
+
+// TreeNode is a node of a Merkle tree. The digest of a directory covers the names, types and digests
+// of all its children, so two trees are equal if their root digests are.
+type TreeNode struct {
+	Name     string
+	Path     string
+	Type     FileType
+	Sum      []byte
+	Children []*TreeNode // sorted by name, nil for everything but directories
+	algo     *c.Codec
+}
+
+// String returns the formatted digest.
+func (n *TreeNode) String() string { return n.algo.Format(n.Sum) }
+
+// Equal returns true if both nodes have the same digest.
+func (n *TreeNode) Equal(other *TreeNode) bool { return other != nil && bytes.Equal(n.Sum, other.Sum) }
+
+// Child returns the direct child named `name` or nil if there is none.
+func (n *TreeNode) Child(name string) *TreeNode {
+	i, ok := slices.BinarySearchFunc(n.Children, name, func(c *TreeNode, name string) int { return strings.Compare(c.Name, name) })
+	if !ok {
+		return nil
+	}
+	return n.Children[i]
+}
+
+// Diff returns the slash-separated paths, relative to the roots, of all entries that differ
+// between both trees. Only mismatching directories are descended into, so a differing
+// directory is only listed itself if it only exists in one of the trees or its type differs.
+// If `other` is nil, the root itself differs.
+func (n *TreeNode) Diff(other *TreeNode) []string {
+	res := []string{}
+	n.diff(other, ".", &res)
+	return res
+}
+
+func (n *TreeNode) diff(other *TreeNode, rel string, res *[]string) {
+	if n.Equal(other) {
+		return
+	}
+	if other == nil || n.Type != TypeDir || other.Type != TypeDir {
+		*res = append(*res, rel)
+		return
+	}
+	i, j := 0, 0
+	for i < len(n.Children) || j < len(other.Children) {
+		switch {
+		case j == len(other.Children) || (i < len(n.Children) && n.Children[i].Name < other.Children[j].Name):
+			*res = append(*res, path.Join(rel, n.Children[i].Name))
+			i++
+		case i == len(n.Children) || n.Children[i].Name > other.Children[j].Name:
+			*res = append(*res, path.Join(rel, other.Children[j].Name))
+			j++
+		default:
+			n.Children[i].diff(other.Children[j], path.Join(rel, n.Children[i].Name), res)
+			i++
+			j++
+		}
+	}
+}
+
+type treeHasher struct {
+	root string
+	algo *c.Codec
+	opts *TreeOptions
+}
+
+// write adds a length-prefixed field to `h`, so that different entries can't produce the same input.
+func (t *treeHasher) write(h hash.Hash, field []byte) {
+	h.Write(binary.BigEndian.AppendUint64(nil, uint64(len(field))))
+	h.Write(field)
+}
+
+// meta adds the metadata selected by the options to `h`.
+func (t *treeHasher) meta(h hash.Hash, f *FileObj) {
+	if t.opts.Permissions {
+		t.write(h, binary.BigEndian.AppendUint32(nil, uint32(permissions.Unix(f.meta().Mode))))
+	}
+	if t.opts.Ownership {
+		o := f.meta().Ownership
+		t.write(h, []byte(o.UID()+":"+o.GID()))
+	}
+}
+
+func (t *treeHasher) file(f *FileObj) (*TreeNode, error) {
+	n := &TreeNode{Name: f.Name(), Path: f.Path(), Type: f.Type(), algo: t.algo}
+	var content []byte
+	switch {
+	case n.Type == TypeDanglingLink || (n.Type == TypeSymlink && (t.opts.Links || !f.meta().Mode.IsRegular())):
+		// only links to files are followed, dangling links can't be and links to directories could lead into cycles
+		target, err := f.Readlink()
+		if err != nil {
+			return nil, err
+		}
+		content = []byte(target)
+	case n.Type == TypeFile || n.Type == TypeSymlink:
+		n.Type = TypeFile // links are followed
+		sum, err := f.Digest(t.algo)
+		if err != nil {
+			return nil, err
+		}
+		content = sum
+	}
+	// everything else (devices, FIFOs and sockets) only has a type
+	h := t.algo.Hash()
+	t.write(h, []byte{byte(n.Type)})
+	t.write(h, content)
+	if n.Type != TypeSymlink && n.Type != TypeDanglingLink {
+		t.meta(h, f)
+	}
+	n.Sum = h.Sum(nil)
+	return n, nil
+}
+
+func (t *treeHasher) dir(d *DirObj, rules *ignore.Matcher) (*TreeNode, error) {
+	n := &TreeNode{Name: d.Name(), Path: d.Path(), Type: TypeDir, Children: []*TreeNode{}, algo: t.algo}
+	walk := t.opts.Walk
+	if walk == nil {
+		walk = DefaultWalkOptions()
+	}
+	rules = walk.loadIgnore(t.root, d, rules)
+	var err error
+	d.entries(false, func(f *FileObj, isDir bool, e error) bool {
+		if e != nil {
+			err = e
+			return false
+		}
+		visit, descend := walk.accept(t.root, f, isDir, rules)
+		var child *TreeNode
+		switch {
+		case descend:
+			child, e = t.dir(f.AsDir(), rules)
+			if e == nil && !visit && len(child.Children) == 0 {
+				return true // not included and nothing included below it
+			}
+		case visit:
+			child, e = t.file(f)
+		default:
+			return true
+		}
+		if e != nil {
+			err = e
+			return false
+		}
+		n.Children = append(n.Children, child)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(n.Children, func(a, b *TreeNode) int { return strings.Compare(a.Name, b.Name) })
+
+	h := t.algo.Hash()
+	t.write(h, []byte{byte(n.Type)})
+	t.meta(h, d.FileObj)
+	for _, child := range n.Children {
+		t.write(h, []byte(child.Name))
+		t.write(h, child.Sum)
+	}
+	n.Sum = h.Sum(nil)
+	return n, nil
+}
+
+// TreeChecksum returns the Merkle tree of the directory, hashed with `algo` (e.g. codec.SHA256).
+// The digest only depends on the names and contents below the directory (and the metadata
+// selected in `opts`), not on the path of the directory itself or the order of the entries.
+// If `opts` is nil, DefaultTreeOptions() are used.
+func (d *DirObj) TreeChecksum(algo *c.Codec, opts *TreeOptions) (*TreeNode, error) {
+	if opts == nil {
+		opts = DefaultTreeOptions()
+	}
+	if algo == nil || algo.Hash == nil {
+		return nil, errors.ErrChecksumAlgorithmInvalid(checksum.Name(algo))
+	}
+	t := &treeHasher{root: d.Path(), algo: algo, opts: opts}
+	return t.dir(d, nil)
+}
@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/toxyl/flo/errors"
+)
+
+// createTemp creates a new, empty file next to `file`. Unlike os.CreateTemp
+// the file is created with 0666 (before umask), just like os.Create would do.
+func createTemp(file string) (*os.File, error) {
+	dir, base := filepath.Split(file)
+	seed := time.Now().UnixNano()
+	for i := 0; i < 100; i++ {
+		name := filepath.Join(dir, "."+base+".tmp-"+strconv.FormatInt(seed+int64(i), 36))
+		f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, errors.ErrFailedToCreateFile(file, err)
+		}
+		return f, nil
+	}
+	return nil, errors.ErrFailedToCreateFile(file, fs.ErrExist)
+}
+
+// AtomicWrite writes to a temporary file next to `file` using `fn`.
+// Only if `fn` succeeds, the temporary file is synced to disk and renamed to `file`,
+// followed by a sync of the parent directory. Mode and ownership of an existing `file`
+// are preserved. If `file` is a symlink, its target will be replaced.
+func AtomicWrite(file string, dirPerm fs.FileMode, fn func(w io.Writer) error) error {
+	if target, e := filepath.EvalSymlinks(file); e == nil {
+		file = target
+	}
+	p := filepath.Dir(file)
+	if err := os.MkdirAll(p, dirPerm); err != nil && !os.IsExist(err) {
+		return errors.ErrFailedToCreateDir(p, err)
+	}
+
+	tmp, err := createTemp(file)
+	if err != nil {
+		return err
+	}
+	renamed := false
+	defer func() {
+		// also covers panicking encoders
+		if !renamed {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	// wrapped so codecs can't close the file before we synced it
+	if err := fn(struct{ io.Writer }{tmp}); err != nil {
+		return errors.ErrFailedToWriteFile(file, err)
+	}
+
+	if stat, e := os.Stat(file); e == nil {
+		mode := stat.Mode() & (fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky)
+		if err := tmp.Chmod(mode); err != nil {
+			return errors.ErrFailedToSetPermissions(tmp.Name(), mode, err)
+		}
+		if err := preserveOwner(tmp, stat); err != nil {
+			return errors.ErrFailedToSetOwner(tmp.Name(), err)
+		}
+	}
+
+	if err := tmp.Sync(); err != nil {
+		return errors.ErrFailedToSyncFile(tmp.Name(), err)
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.ErrFailedToWriteFile(tmp.Name(), err)
+	}
+	if err := os.Rename(tmp.Name(), file); err != nil {
+		return errors.ErrFailedToRenameFile(tmp.Name(), file, err)
+	}
+	renamed = true
+	if err := syncDir(p); err != nil {
+		return errors.ErrFailedToSyncFile(p, err)
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+//go:build linux
+
+package utils
+
+import (
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// preserveOwner gives `tmp` the same owner as described by `stat`.
+// Lacking the privileges to do so is not considered an error,
+// the file will then be owned by the current user.
+func preserveOwner(tmp *os.File, stat fs.FileInfo) error {
+	st, ok := stat.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	if err := tmp.Chown(int(st.Uid), int(st.Gid)); err != nil && !os.IsPermission(err) {
+		return err
+	}
+	return nil
+}
+
+func syncDir(path string) error {
+	dir, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
@@ -0,0 +1,15 @@
+//go:build windows
+
+package utils
+
+import (
+	"io/fs"
+	"os"
+)
+
+// windows doesn't have the same ownership concept as linux,
+// new files are owned by the current user anyway
+func preserveOwner(tmp *os.File, stat fs.FileInfo) error { return nil }
+
+// directories can't be synced on windows, the rename is durable once it returns
+func syncDir(path string) error { return nil }
@@ -43,7 +43,11 @@ func Mkfile(file string, dirPerm, filePerm fs.FileMode) (*os.File, error) {
 		return nil, errors.ErrFailedToCreateDir(p, err)
 	}
 
-	return os.Create(file)
+	f, err := os.Create(file)
+	if err != nil {
+		return nil, errors.ErrFailedToCreateFile(file, err)
+	}
+	return f, nil
 }
 
 func GetFileModeL(path string) fs.FileMode {
@@ -0,0 +1,133 @@
+package utils
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/toxyl/flo/ownership"
+)
+
+func write(s string) func(w io.Writer) error {
+	return func(w io.Writer) error {
+		_, err := io.WriteString(w, s)
+		return err
+	}
+}
+
+// entries returns the names of all entries in `dir`.
+func entries(t *testing.T, dir string) []string {
+	t.Helper()
+	list, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, e := range list {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+func TestAtomicWrite(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "sub", "file")
+
+	t.Run("create and replace", func(t *testing.T) {
+		if err := AtomicWrite(file, 0755, write("one")); err != nil {
+			t.Fatal(err)
+		}
+		if err := AtomicWrite(file, 0755, write("two")); err != nil {
+			t.Fatal(err)
+		}
+		if b, err := os.ReadFile(file); err != nil || string(b) != "two" {
+			t.Fatalf("expected the file to be replaced, got %q, %v", b, err)
+		}
+		if names := entries(t, filepath.Dir(file)); len(names) != 1 {
+			t.Errorf("expected only the file, got %v", names)
+		}
+	})
+	t.Run("cleanup on failure", func(t *testing.T) {
+		fail := func(w io.Writer) error {
+			_, _ = io.WriteString(w, "partial")
+			return fs.ErrInvalid
+		}
+		if err := AtomicWrite(file, 0755, fail); err == nil {
+			t.Fatal("expected an error")
+		}
+		func() {
+			defer func() { _ = recover() }()
+			_ = AtomicWrite(file, 0755, func(w io.Writer) error { panic("encoder failed") })
+		}()
+		if b, err := os.ReadFile(file); err != nil || string(b) != "two" {
+			t.Errorf("expected the file to be unchanged, got %q, %v", b, err)
+		}
+		for _, name := range entries(t, filepath.Dir(file)) {
+			if strings.Contains(name, ".tmp-") {
+				t.Errorf("temporary file %s has not been removed", name)
+			}
+		}
+	})
+	t.Run("preserve mode", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("no unix permissions")
+		}
+		if err := os.Chmod(file, 0640|fs.ModeSetgid); err != nil {
+			t.Fatal(err)
+		}
+		if err := AtomicWrite(file, 0755, write("three")); err != nil {
+			t.Fatal(err)
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mode := info.Mode() & (fs.ModePerm | fs.ModeSetgid); mode != 0640|fs.ModeSetgid {
+			t.Errorf("expected the mode to be preserved, got %v", mode)
+		}
+	})
+	t.Run("preserve owner", func(t *testing.T) {
+		if runtime.GOOS == "windows" || os.Getuid() != 0 {
+			t.Skip("requires root")
+		}
+		nobody, err := user.Lookup("nobody")
+		if err != nil {
+			t.Skip(err)
+		}
+		uid, _ := strconv.Atoi(nobody.Uid)
+		gid, _ := strconv.Atoi(nobody.Gid)
+		if err := os.Chown(file, uid, gid); err != nil {
+			t.Fatal(err)
+		}
+		if err := AtomicWrite(file, 0755, write("four")); err != nil {
+			t.Fatal(err)
+		}
+		if o := ownership.New(file); o.UID() != nobody.Uid || o.GID() != nobody.Gid {
+			t.Errorf("expected the owner to be preserved, got %s:%s", o.UID(), o.GID())
+		}
+	})
+	t.Run("symlink", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("symlinks require privileges")
+		}
+		link := filepath.Join(dir, "link")
+		if err := os.Symlink(file, link); err != nil {
+			t.Fatal(err)
+		}
+		if err := AtomicWrite(link, 0755, write("five")); err != nil {
+			t.Fatal(err)
+		}
+		if target, err := os.Readlink(link); err != nil || target != file {
+			t.Errorf("expected the link to be kept, got %q, %v", target, err)
+		}
+		if b, err := os.ReadFile(file); err != nil || string(b) != "five" {
+			t.Errorf("expected the target to be replaced, got %q, %v", b, err)
+		}
+	})
+}
@@ -4,6 +4,7 @@ import "strings"
 
 type StringIO struct {
 	strings.Builder
+	off int // number of bytes read so far
 }
 
 func (s *StringIO) Write(p []byte) (n int, err error) {
@@ -11,7 +12,9 @@ func (s *StringIO) Write(p []byte) (n int, err error) {
 }
 
 func (s *StringIO) Read(p []byte) (n int, err error) {
-	return strings.NewReader(s.String()).Read(p)
+	n, err = strings.NewReader(s.String()[s.off:]).Read(p)
+	s.off += n
+	return n, err
 }
 
 func NewStringIO(str string) *StringIO {
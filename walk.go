@@ -0,0 +1,413 @@
+package flo
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"runtime"
+	"sync"
+
+	"github.com/toxyl/flo/glob"
+	"github.com/toxyl/flo/ignore"
+)
+
+var (
+	// SkipDir can be returned by a WalkFunc to skip the directory it was called with.
+	// When returned for a file, the remaining files of the same directory are skipped.
+	SkipDir = fs.SkipDir
+	// SkipAll can be returned by a WalkFunc to stop the walk without returning an error.
+	SkipAll = fs.SkipAll
+)
+
+// WalkFunc is called for every file and directory visited by a walk.
+// Use f.IsDir() to distinguish between both and f.AsDir() to work with a directory.
+type WalkFunc func(f *FileObj) error
+
+// FollowMode controls which symlinks to directories a walk descends into.
+type FollowMode int
+
+const (
+	// FollowNever doesn't follow any links, links to directories are visited like files.
+	// If the walked directory itself is a link, nothing is visited.
+	FollowNever FollowMode = iota
+	// FollowRoot only follows the walked directory if it is a link (like find -H).
+	FollowRoot
+	// FollowAlways follows all links to directories (like find -L).
+	// Links pointing to one of their parent directories are not descended into.
+	FollowAlways
+)
+
+type WalkOptions struct {
+	// Workers is the number of directories read concurrently, defaults to the number of CPUs.
+	Workers int
+	// MaxDepth limits the recursion depth, 0 only visits the contents of the walked directory.
+	// Use a negative value to walk the entire tree.
+	MaxDepth int
+	// Ordered makes the walk call the WalkFunc sequentially and in the same order as Each does.
+	// Otherwise the WalkFunc is called concurrently from all workers and must be safe for that.
+	Ordered bool
+	// OnError is called for directories that can't be read. If it returns an error, the walk stops
+	// with that error. If it is nil, unreadable directories are skipped silently (just like Each does).
+	OnError func(d *DirObj, err error) error
+	// Filter limits the walk to entries whose path relative to the walked directory matches.
+	// Excluded directories are not descended into, directories that are not included are
+	// still descended into but not passed to the WalkFunc.
+	Filter *glob.Filter
+	// IgnoreFiles are the names of ignore files (e.g. ".gitignore" or ".dockerignore") that are loaded
+	// from every directory the walk descends into. Their rules use gitignore semantics and apply to the
+	// directory they are found in and everything below it. Ignored entries are skipped entirely.
+	IgnoreFiles []string
+	// Follow controls which links to directories are descended into, defaults to FollowNever.
+	Follow FollowMode
+}
+
+func DefaultWalkOptions() *WalkOptions {
+	return &WalkOptions{
+		Workers:     runtime.NumCPU(),
+		MaxDepth:    -1,
+		Ordered:     false,
+		OnError:     nil,
+		Filter:      nil,
+		IgnoreFiles: nil,
+		Follow:      FollowNever,
+	}
+}
+
+// follow returns whether links in the directories below the root are followed.
+func (o *WalkOptions) follow() bool { return o.Follow == FollowAlways }
+
+// skipRoot returns true if `d` is a link that must not be walked.
+func (o *WalkOptions) skipRoot(d *DirObj) bool { return o.Follow == FollowNever && d.IsSymlink() }
+
+// accept checks `f` against the filter and the ignore `rules` of the options. It returns whether
+// `f` should be passed to the caller and whether the walk may descend into it.
+func (o *WalkOptions) accept(root string, f *FileObj, isDir bool, rules *ignore.Matcher) (visit, descend bool) {
+	if o.Filter == nil && rules == nil {
+		return true, isDir
+	}
+	rel, err := filepath.Rel(root, f.Path())
+	if err != nil {
+		return true, isDir
+	}
+	if rules.Match(rel, isDir) || o.Filter.Excluded(rel) {
+		return false, false
+	}
+	return o.Filter.Included(rel), isDir
+}
+
+// loadIgnore returns the ignore rules for the entries of `d`, i.e. the rules of its parents
+// (`rules`) and those of the ignore files in `d`. Unreadable ignore files are skipped.
+func (o *WalkOptions) loadIgnore(root string, d *DirObj, rules *ignore.Matcher) *ignore.Matcher {
+	if len(o.IgnoreFiles) == 0 {
+		return rules
+	}
+	rel, err := filepath.Rel(root, d.Path())
+	if err != nil {
+		return rules
+	}
+	if rel == "." {
+		rel = ""
+	}
+	res, _ := rules.Load(d.Path(), rel, o.IgnoreFiles...)
+	return res
+}
+
+type walkListing struct {
+	anc   *ancestors // of dirs and files
+	dirs  []*DirObj
+	files []*FileObj
+	rules *ignore.Matcher // applies to dirs and files
+	err   error
+}
+
+type walkJob struct {
+	dir    *DirObj
+	depth  int
+	rules  *ignore.Matcher  // ignore rules of the parent directories
+	anc    *ancestors       // the directory and its parents
+	result chan walkListing // only used for ordered walks
+}
+
+// walkQueue is an unbounded FIFO of directories to read.
+// Workers stop once the queue is closed or when it is empty and no job is pending anymore.
+type walkQueue struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	jobs    []*walkJob
+	pending int
+	closed  bool
+}
+
+func (q *walkQueue) push(j *walkJob) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return
+	}
+	q.jobs = append(q.jobs, j)
+	q.pending++
+	q.cond.Signal()
+}
+
+func (q *walkQueue) next() *walkJob {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.jobs) == 0 && q.pending > 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if q.closed || len(q.jobs) == 0 {
+		return nil
+	}
+	j := q.jobs[0]
+	q.jobs[0] = nil
+	q.jobs = q.jobs[1:]
+	return j
+}
+
+func (q *walkQueue) hold() {
+	q.mu.Lock()
+	q.pending++
+	q.mu.Unlock()
+}
+
+func (q *walkQueue) done() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.pending--
+	if q.pending <= 0 {
+		q.cond.Broadcast()
+	}
+}
+
+func (q *walkQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	q.jobs = nil
+	q.cond.Broadcast()
+}
+
+type walker struct {
+	root   string
+	ctx    context.Context
+	cancel context.CancelFunc
+	opts   *WalkOptions
+	fn     WalkFunc
+	queue  *walkQueue
+	once   sync.Once
+	err    error
+}
+
+func (w *walker) stop(err error) {
+	w.once.Do(func() {
+		w.err = err
+		w.cancel()
+	})
+}
+
+func (w *walker) descend(depth int) bool {
+	return w.opts.MaxDepth < 0 || depth <= w.opts.MaxDepth
+}
+
+func (w *walker) handleError(d *DirObj, err error) error {
+	if w.opts.OnError == nil {
+		return nil
+	}
+	return w.opts.OnError(d, err)
+}
+
+func (w *walker) work() {
+	for j := w.queue.next(); j != nil; j = w.queue.next() {
+		if w.opts.Ordered {
+			dirs, files, err := j.dir.list(w.opts.follow())
+			rules := w.opts.loadIgnore(w.root, j.dir, j.rules)
+			j.result <- walkListing{anc: j.anc, dirs: dirs, files: files, rules: rules, err: err}
+		} else {
+			w.visit(j)
+		}
+		w.queue.done()
+	}
+}
+
+// visit reads the directory of `j`, calls the WalkFunc for all entries and queues the subdirectories.
+func (w *walker) visit(j *walkJob) {
+	if w.ctx.Err() != nil {
+		return
+	}
+	dirs, files, err := j.dir.list(w.opts.follow())
+	if err != nil {
+		if err := w.handleError(j.dir, err); err != nil {
+			w.stop(err)
+		}
+		return
+	}
+	rules := w.opts.loadIgnore(w.root, j.dir, j.rules)
+	for _, d := range dirs {
+		if w.ctx.Err() != nil {
+			return
+		}
+		visit, descend := w.opts.accept(w.root, d.FileObj, true, rules)
+		if visit {
+			if err := w.fn(d.FileObj); err == SkipDir {
+				continue
+			} else if err != nil {
+				w.stop(err)
+				return
+			}
+		}
+		if !descend || !w.descend(j.depth+1) {
+			continue
+		}
+		if err := j.anc.check(d); err != nil {
+			if err := w.handleError(d, err); err != nil {
+				w.stop(err)
+				return
+			}
+			continue
+		}
+		w.queue.push(&walkJob{dir: d, depth: j.depth + 1, rules: rules, anc: j.anc.push(d.Path())})
+	}
+	for _, f := range files {
+		if w.ctx.Err() != nil {
+			return
+		}
+		if visit, _ := w.opts.accept(w.root, f, false, rules); !visit {
+			continue
+		}
+		if err := w.fn(f); err == SkipDir {
+			break
+		} else if err != nil {
+			w.stop(err)
+			return
+		}
+	}
+}
+
+// ordered delivers the listing of `j` in the same order as Each would, while the workers
+// are already reading the subdirectories.
+func (w *walker) ordered(j *walkJob) error {
+	var l walkListing
+	select {
+	case l = <-j.result:
+	case <-w.ctx.Done():
+		return w.ctx.Err()
+	}
+	if l.err != nil {
+		return w.handleError(j.dir, l.err)
+	}
+
+	jobs := make([]*walkJob, len(l.dirs))
+	visits := make([]bool, len(l.dirs))
+	cycles := make([]error, len(l.dirs))
+	for i, d := range l.dirs {
+		visit, descend := w.opts.accept(w.root, d.FileObj, true, l.rules)
+		visits[i] = visit
+		if !descend || !w.descend(j.depth+1) {
+			continue
+		}
+		if cycles[i] = l.anc.check(d); cycles[i] == nil {
+			jobs[i] = &walkJob{dir: d, depth: j.depth + 1, rules: l.rules, anc: l.anc.push(d.Path()), result: make(chan walkListing, 1)}
+			w.queue.push(jobs[i])
+		}
+	}
+
+	for i, d := range l.dirs {
+		if err := w.ctx.Err(); err != nil {
+			return err
+		}
+		if visits[i] {
+			if err := w.fn(d.FileObj); err == SkipDir {
+				continue
+			} else if err != nil {
+				return err
+			}
+		}
+		if jobs[i] != nil {
+			if err := w.ordered(jobs[i]); err != nil {
+				return err
+			}
+		} else if cycles[i] != nil {
+			if err := w.handleError(d, cycles[i]); err != nil {
+				return err
+			}
+		}
+	}
+	for _, f := range l.files {
+		if err := w.ctx.Err(); err != nil {
+			return err
+		}
+		if visit, _ := w.opts.accept(w.root, f, false, l.rules); !visit {
+			continue
+		}
+		if err := w.fn(f); err == SkipDir {
+			break
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WalkParallel walks the directory tree using a pool of workers and calls `fn` for every file and directory.
+// If `opts` is nil, DefaultWalkOptions() are used.
+//
+// The walk stops when `ctx` is cancelled or `fn` returns an error. `fn` can return SkipDir to
+// skip a directory and SkipAll to stop the walk without error. Unlike Each, the WalkFunc
+// is called concurrently, unless `opts.Ordered` is set. Links that would lead into a cycle
+// are reported to `opts.OnError` with errors.ErrSymlinkCycle and not descended into.
+func (d *DirObj) WalkParallel(ctx context.Context, opts *WalkOptions, fn WalkFunc) error {
+	if opts == nil {
+		opts = DefaultWalkOptions()
+	}
+	if opts.skipRoot(d) {
+		return nil
+	}
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	wctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	w := &walker{
+		root:   d.Path(),
+		ctx:    wctx,
+		cancel: cancel,
+		opts:   opts,
+		fn:     fn,
+		queue:  &walkQueue{},
+	}
+	w.queue.cond = sync.NewCond(&w.queue.mu)
+	defer context.AfterFunc(wctx, w.queue.close)()
+
+	// the root has to be queued before the workers start, otherwise they find
+	// an empty queue with nothing pending and exit right away
+	root := &walkJob{dir: d, depth: 0, anc: (*ancestors)(nil).push(d.Path())}
+	if opts.Ordered {
+		root.result = make(chan walkListing, 1)
+		w.queue.hold() // keeps the workers alive while we deliver
+	}
+	w.queue.push(root)
+
+	wg := sync.WaitGroup{}
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.work()
+		}()
+	}
+	if opts.Ordered {
+		w.stop(w.ordered(root))
+		w.queue.close()
+	}
+	wg.Wait()
+
+	if w.err == SkipAll {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return w.err
+}